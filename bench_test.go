@@ -0,0 +1,118 @@
+package gosmt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// benchSizes are the number of keys in the benchmarked trees.
+var benchSizes = []int{1 << 6, 1 << 8, 1 << 10}
+
+// benchUpdateSize is the number of keys in a benchmarked update.
+const benchUpdateSize = 64
+
+// benchCaches are the caching strategies to benchmark.
+var benchCaches = []struct {
+	name string
+	new  func() Cache
+}{
+	{"Nothing", func() Cache { return CacheNothing(0) }},
+	{"B-0.5", func() Cache { return NewCacheBranchMinus(0.5) }},
+	{"B", func() Cache { return CacheBranch(make(map[string][]byte)) }},
+	{"B+", func() Cache { return CacheBranchPlus(make(map[string][]byte)) }},
+}
+
+// benchData returns n sorted keys that are the same for every run, so that
+// results can be compared between commits.
+func benchData(n, offset int) D {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = hash(binary.BigEndian.AppendUint64(nil, uint64(offset+i)))
+	}
+	return NewKeys(keys...)
+}
+
+// benchmark runs f for every cache strategy and size with a SMT holding
+// the keys data.
+func benchmark(b *testing.B, f func(b *testing.B, s *SMT, data D)) {
+	for _, size := range benchSizes {
+		data := benchData(size, 0)
+		for _, c := range benchCaches {
+			b.Run(fmt.Sprintf("%s/%d", c.name, size), func(b *testing.B) {
+				s := NewSMT([]byte{0x42}, c.new(), hash)
+				s.BulkLoad(data)
+				b.ReportAllocs()
+				b.ResetTimer()
+				f(b, s, data)
+			})
+		}
+	}
+}
+
+// BenchmarkUpdate alternates between adding and removing benchUpdateSize
+// keys.
+func BenchmarkUpdate(b *testing.B) {
+	benchmark(b, func(b *testing.B, s *SMT, data D) {
+		keys := benchData(benchUpdateSize, data.Len())
+		added := data.Insert(keys.All()...)
+		for i := 0; i < b.N; i++ {
+			if i%2 == 0 {
+				s.Update(added, keys, s.N, s.Base, Set)
+			} else {
+				s.Update(data, keys, s.N, s.Base, Empty)
+			}
+		}
+	})
+}
+
+// BenchmarkRootHash computes the root from the keys alone, with a fresh
+// cache for every strategy in each iteration: a loaded cache holds the root
+// and would only measure a lookup.
+func BenchmarkRootHash(b *testing.B) {
+	for _, size := range benchSizes {
+		data := benchData(size, 0)
+		for _, c := range benchCaches {
+			b.Run(fmt.Sprintf("%s/%d", c.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					s := NewSMT([]byte{0x42}, c.new(), hash)
+					b.StartTimer()
+					s.RootHash(data, s.N, s.Base)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkAuditPath generates audit paths for members and non-members.
+func BenchmarkAuditPath(b *testing.B) {
+	benchmark(b, func(b *testing.B, s *SMT, data D) {
+		keys := append(benchData(benchUpdateSize, data.Len()).All(),
+			data.Slice(0, benchUpdateSize).All()...)
+		for i := 0; i < b.N; i++ {
+			s.AuditPath(data, s.N, s.Base, keys[i%len(keys)])
+		}
+	})
+}
+
+// BenchmarkVerifyAuditPath verifies audit paths of members, generated before
+// the timer starts.
+func BenchmarkVerifyAuditPath(b *testing.B) {
+	benchmark(b, func(b *testing.B, s *SMT, data D) {
+		root := s.RootHash(data, s.N, s.Base)
+		keys := data.Slice(0, benchUpdateSize).All()
+		aps := make([][][]byte, len(keys))
+		for i, key := range keys {
+			aps[i] = s.AuditPath(data, s.N, s.Base, key)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			j := i % len(keys)
+			if !s.VerifyAuditPath(aps[j], keys[j], Set, root) {
+				b.Fatal("failed to verify valid proof")
+			}
+		}
+	})
+}
@@ -0,0 +1,330 @@
+package gosmt
+
+import (
+	"bytes"
+	"sort"
+)
+
+// btreeDegree is the minimum degree of a BTree: every node except the root
+// has between btreeDegree-1 and 2*btreeDegree-1 keys.
+const btreeDegree = 32
+
+// BTree is an in-memory B-tree of keys that implements LeafStore. Every node
+// keeps the number of keys below it, so counting keys in a range, and hence
+// splitting, takes O(log n).
+type BTree struct {
+	root *btreeNode
+}
+
+type btreeNode struct {
+	keys     [][]byte
+	children []*btreeNode // nil for leaf nodes
+	size     int          // number of keys in the subtree
+}
+
+// NewBTree creates a new BTree with the provided keys.
+func NewBTree(keys ...[]byte) *BTree {
+	t := new(BTree)
+	for _, key := range keys {
+		t.Insert(key)
+	}
+	return t
+}
+
+// Insert adds key to the tree, returning false if it already exists.
+func (t *BTree) Insert(key []byte) bool {
+	if t.Has(key) {
+		return false
+	}
+	if t.root == nil {
+		t.root = new(btreeNode)
+	}
+	if len(t.root.keys) == 2*btreeDegree-1 {
+		old := t.root
+		t.root = &btreeNode{children: []*btreeNode{old}, size: old.size}
+		t.root.splitChild(0)
+	}
+	t.root.insert(key)
+	return true
+}
+
+// Delete removes key from the tree, returning false if it does not exist.
+func (t *BTree) Delete(key []byte) bool {
+	if !t.Has(key) {
+		return false
+	}
+	t.root.delete(key)
+	if len(t.root.keys) == 0 {
+		if t.root.leaf() {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+	return true
+}
+
+// Has checks if key is in the tree.
+func (t *BTree) Has(key []byte) bool {
+	for n := t.root; n != nil; {
+		i := n.search(key)
+		if i < len(n.keys) && bytes.Equal(n.keys[i], key) {
+			return true
+		}
+		if n.leaf() {
+			return false
+		}
+		n = n.children[i]
+	}
+	return false
+}
+
+// Len returns the number of keys in the tree.
+func (t *BTree) Len() int {
+	if t.root == nil {
+		return 0
+	}
+	return t.root.size
+}
+
+// Count returns the number of keys k with lo <= k < hi, where a nil hi is
+// the end of the key space.
+func (t *BTree) Count(lo, hi []byte) int {
+	if hi == nil {
+		return t.Len() - t.root.rank(lo)
+	}
+	if bytes.Compare(lo, hi) >= 0 {
+		return 0
+	}
+	return t.root.rank(hi) - t.root.rank(lo)
+}
+
+// SplitAt splits the tree into views of the keys smaller than s and the rest.
+func (t *BTree) SplitAt(s []byte) (l, r LeafStore) {
+	return &btreeRange{t: t, hi: s}, &btreeRange{t: t, lo: s}
+}
+
+// Iterate calls f with each key in ascending order until f returns false.
+func (t *BTree) Iterate(f func(key []byte) bool) {
+	t.root.ascend(nil, nil, f)
+}
+
+// btreeRange is a view of the keys k in a BTree with lo <= k < hi.
+type btreeRange struct {
+	t      *BTree
+	lo, hi []byte
+}
+
+func (r *btreeRange) Len() int { return r.t.Count(r.lo, r.hi) }
+
+func (r *btreeRange) Count(lo, hi []byte) int {
+	return r.t.Count(maxLo(r.lo, lo), minHi(r.hi, hi))
+}
+
+func (r *btreeRange) SplitAt(s []byte) (left, right LeafStore) {
+	return &btreeRange{t: r.t, lo: r.lo, hi: minHi(r.hi, s)},
+		&btreeRange{t: r.t, lo: maxLo(r.lo, s), hi: r.hi}
+}
+
+func (r *btreeRange) Iterate(f func(key []byte) bool) {
+	r.t.root.ascend(r.lo, r.hi, f)
+}
+
+func (n *btreeNode) leaf() bool { return n.children == nil }
+
+// search returns the smallest index i where n.keys[i] >= key.
+func (n *btreeNode) search(key []byte) int {
+	return sort.Search(len(n.keys), func(i int) bool {
+		return bytes.Compare(n.keys[i], key) >= 0
+	})
+}
+
+// count returns the number of keys in n and its children.
+func (n *btreeNode) count() int {
+	size := len(n.keys)
+	for _, c := range n.children {
+		size += c.size
+	}
+	return size
+}
+
+// rank returns the number of keys smaller than key.
+func (n *btreeNode) rank(key []byte) int {
+	if n == nil {
+		return 0
+	}
+	i := n.search(key)
+	if n.leaf() {
+		return i
+	}
+	r := i
+	for j := 0; j < i; j++ {
+		r += n.children[j].size
+	}
+	return r + n.children[i].rank(key)
+}
+
+// ascend calls f with each key k with lo <= k < hi in ascending order, until
+// f returns false. Returns false if iteration should stop.
+func (n *btreeNode) ascend(lo, hi []byte, f func(key []byte) bool) bool {
+	if n == nil {
+		return true
+	}
+	for i := n.search(lo); i <= len(n.keys); i++ {
+		if !n.leaf() && !n.children[i].ascend(lo, hi, f) {
+			return false
+		}
+		if i == len(n.keys) {
+			break
+		}
+		if hi != nil && bytes.Compare(n.keys[i], hi) >= 0 {
+			return false
+		}
+		if !f(n.keys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// insert inserts key, not in the tree, into the subtree of n that is not full.
+func (n *btreeNode) insert(key []byte) {
+	n.size++
+	i := n.search(key)
+	if n.leaf() {
+		n.keys = append(n.keys, nil)
+		copy(n.keys[i+1:], n.keys[i:])
+		n.keys[i] = key
+		return
+	}
+	if len(n.children[i].keys) == 2*btreeDegree-1 {
+		n.splitChild(i)
+		if bytes.Compare(key, n.keys[i]) > 0 {
+			i++
+		}
+	}
+	n.children[i].insert(key)
+}
+
+// splitChild splits the full child i of n around its median key.
+func (n *btreeNode) splitChild(i int) {
+	c := n.children[i]
+	median := c.keys[btreeDegree-1]
+	m := new(btreeNode)
+	m.keys = append([][]byte{}, c.keys[btreeDegree:]...)
+	c.keys = c.keys[: btreeDegree-1 : btreeDegree-1]
+	if !c.leaf() {
+		m.children = append([]*btreeNode{}, c.children[btreeDegree:]...)
+		c.children = c.children[:btreeDegree:btreeDegree]
+	}
+	c.size, m.size = c.count(), m.count()
+
+	n.keys = append(n.keys, nil)
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = median
+	n.children = append(n.children, nil)
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = m
+}
+
+// delete removes key, in the tree, from the subtree of n. Every node visited
+// except the root has at least btreeDegree keys.
+func (n *btreeNode) delete(key []byte) {
+	defer func() { n.size = n.count() }()
+	i := n.search(key)
+	found := i < len(n.keys) && bytes.Equal(n.keys[i], key)
+	if n.leaf() {
+		if found {
+			n.keys = append(n.keys[:i], n.keys[i+1:]...)
+		}
+		return
+	}
+
+	if found {
+		switch {
+		case len(n.children[i].keys) >= btreeDegree:
+			pred := n.children[i].max()
+			n.keys[i] = pred
+			n.children[i].delete(pred)
+		case len(n.children[i+1].keys) >= btreeDegree:
+			succ := n.children[i+1].min()
+			n.keys[i] = succ
+			n.children[i+1].delete(succ)
+		default:
+			n.merge(i)
+			n.children[i].delete(key)
+		}
+		return
+	}
+
+	// make sure the child to descend into has at least btreeDegree keys
+	if len(n.children[i].keys) < btreeDegree {
+		switch {
+		case i > 0 && len(n.children[i-1].keys) >= btreeDegree:
+			n.rotateRight(i - 1)
+		case i < len(n.keys) && len(n.children[i+1].keys) >= btreeDegree:
+			n.rotateLeft(i)
+		case i < len(n.keys):
+			n.merge(i)
+		default:
+			n.merge(i - 1)
+			i--
+		}
+	}
+	n.children[i].delete(key)
+}
+
+// merge merges key i and child i+1 of n into child i.
+func (n *btreeNode) merge(i int) {
+	c, sibling := n.children[i], n.children[i+1]
+	c.keys = append(append(c.keys, n.keys[i]), sibling.keys...)
+	if !c.leaf() {
+		c.children = append(c.children, sibling.children...)
+	}
+	c.size = c.count()
+	n.keys = append(n.keys[:i], n.keys[i+1:]...)
+	n.children = append(n.children[:i+1], n.children[i+2:]...)
+}
+
+// rotateRight moves key i of n down to child i+1 and the largest key of
+// child i up.
+func (n *btreeNode) rotateRight(i int) {
+	l, r := n.children[i], n.children[i+1]
+	r.keys = append([][]byte{n.keys[i]}, r.keys...)
+	n.keys[i] = l.keys[len(l.keys)-1]
+	l.keys = l.keys[:len(l.keys)-1]
+	if !l.leaf() {
+		r.children = append([]*btreeNode{l.children[len(l.children)-1]},
+			r.children...)
+		l.children = l.children[:len(l.children)-1]
+	}
+	l.size, r.size = l.count(), r.count()
+}
+
+// rotateLeft moves key i of n down to child i and the smallest key of child
+// i+1 up.
+func (n *btreeNode) rotateLeft(i int) {
+	l, r := n.children[i], n.children[i+1]
+	l.keys = append(l.keys, n.keys[i])
+	n.keys[i] = r.keys[0]
+	r.keys = r.keys[1:]
+	if !r.leaf() {
+		l.children = append(l.children, r.children[0])
+		r.children = r.children[1:]
+	}
+	l.size, r.size = l.count(), r.count()
+}
+
+func (n *btreeNode) min() []byte {
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return n.keys[0]
+}
+
+func (n *btreeNode) max() []byte {
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	return n.keys[len(n.keys)-1]
+}
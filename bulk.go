@@ -0,0 +1,25 @@
+package gosmt
+
+// BulkLoad fills the (empty) cache with all keys in d and returns the root
+// hash. It computes the same as Update(d, Key(d), s.N, s.Base, Set), but
+// only splits d, which is sorted, and never has to split or sort the keys.
+func (s *SMT) BulkLoad(d LeafStore) []byte {
+	*s.gen++
+	return s.bulkLoad(leafStore(d), s.N, s.Base)
+}
+
+func (s *SMT) bulkLoad(d LeafStore, height uint64, base []byte) []byte {
+	switch {
+	case d.Len() == 0:
+		return s.defaultHash(height)
+	case d.Len() == 1 && height == 0:
+		return s.leafHash(s.leafValue(base), base)
+	case d.Len() > 0 && height == 0:
+		panic("this should never happen (unsorted store or duplicate keys?)")
+	}
+	split := bitSplit(base, s.N-height)
+	l, r := d.SplitAt(split)
+	return s.cache.HashCache(s.bulkLoad(l, height-1, base),
+		s.bulkLoad(r, height-1, split),
+		height, base, split, s.interiorHash, s.defaultHashes)
+}
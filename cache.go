@@ -17,6 +17,55 @@ type Cache interface {
 	Entries() int
 }
 
+// CacheSizer is optionally implemented by a Cache that can estimate its
+// memory use, as all caching strategies of this package do.
+type CacheSizer interface {
+	// Size returns an estimate of the memory used by the cache in bytes.
+	Size() int
+}
+
+// stagingCache is a Cache whose writes can be staged in an overlay, see Tx.
+type stagingCache interface {
+	Cache
+	CacheSizer
+	// cache calls set for every entry HashCache writes for a node with hash h,
+	// where a nil value deletes the entry.
+	cache(h, left, right []byte, height uint64, base, split []byte,
+		defaultHashes [][]byte, set func(key string, value []byte))
+	// set writes an entry, or deletes it for a nil value.
+	set(key string, value []byte)
+	// has checks if an entry exists.
+	has(key string) bool
+}
+
+// mapEntryOverhead is an estimate of the bytes used per entry of a
+// map[string][]byte beyond the key and value bytes: the string and slice
+// headers in a slot, the control byte, and the free slots of a map that
+// doubles in size as it grows.
+const mapEntryOverhead = 64
+
+// mapHeaderSize is an estimate of the bytes used by an empty map.
+const mapHeaderSize = 48
+
+// mapSize returns an estimate of the bytes used by m, with keys and values
+// rounded up to the 16-byte size classes they are allocated in.
+func mapSize(m map[string][]byte) int {
+	size := mapHeaderSize
+	for key, value := range m {
+		size += mapEntryOverhead + roundUp(len(key), 16) + roundUp(cap(value), 16)
+	}
+	return size
+}
+
+func roundUp(n, m int) int {
+	return (n + m - 1) / m * m
+}
+
+// cacheKey returns the key of the entry for a node in a cache.
+func cacheKey(height uint64, base []byte) string {
+	return strconv.Itoa(int(height)) + string(base)
+}
+
 // CacheNothing caches nothing.
 type CacheNothing int
 
@@ -38,18 +87,29 @@ func (c CacheNothing) Entries() int {
 	return 0
 }
 
+// Size returns an estimate of the memory used by the cache in bytes.
+func (c CacheNothing) Size() int {
+	return 0
+}
+
+func (c CacheNothing) cache(h, left, right []byte, height uint64, base, split []byte,
+	defaultHashes [][]byte, set func(key string, value []byte)) {
+}
+func (c CacheNothing) set(key string, value []byte) {}
+func (c CacheNothing) has(key string) bool          { return false }
+
 // CacheBranch caches every branch where both children have non-default values.
 type CacheBranch map[string][]byte
 
 // Exists checks if a value exists in the cache.
 func (c CacheBranch) Exists(height uint64, base []byte) bool {
-	_, exists := c[strconv.Itoa(int(height))+string(base)]
+	_, exists := c[cacheKey(height, base)]
 	return exists
 }
 
 // Get returns a value that exists from the cache.
 func (c CacheBranch) Get(height uint64, base []byte) []byte {
-	return c[strconv.Itoa(int(height))+string(base)]
+	return c[cacheKey(height, base)]
 }
 
 // HashCache hashes the provided values and maybe caches.
@@ -57,11 +117,7 @@ func (c CacheBranch) HashCache(left, right []byte, height uint64, base, split []
 	interiorHash func(left, right []byte, height uint64, base []byte) []byte,
 	defaultHashes [][]byte) []byte {
 	h := interiorHash(left, right, height, base)
-	if !bytes.Equal(defaultHashes[height-1], left) && !bytes.Equal(defaultHashes[height-1], right) {
-		c[strconv.Itoa(int(height))+string(base)] = h
-	} else {
-		delete(c, strconv.Itoa(int(height))+string(base))
-	}
+	c.cache(h, left, right, height, base, split, defaultHashes, c.set)
 	return h
 }
 
@@ -70,18 +126,45 @@ func (c CacheBranch) Entries() int {
 	return len(c)
 }
 
+// Size returns an estimate of the memory used by the cache in bytes.
+func (c CacheBranch) Size() int {
+	return mapSize(c)
+}
+
+func (c CacheBranch) cache(h, left, right []byte, height uint64, base, split []byte,
+	defaultHashes [][]byte, set func(key string, value []byte)) {
+	if !bytes.Equal(defaultHashes[height-1], left) && !bytes.Equal(defaultHashes[height-1], right) {
+		set(cacheKey(height, base), h)
+	} else {
+		set(cacheKey(height, base), nil)
+	}
+}
+
+func (c CacheBranch) set(key string, value []byte) {
+	if value == nil {
+		delete(c, key)
+	} else {
+		c[key] = value
+	}
+}
+
+func (c CacheBranch) has(key string) bool {
+	_, exists := c[key]
+	return exists
+}
+
 // CacheBranchPlus caches the two children of every branch where both children have non-default values.
 type CacheBranchPlus map[string][]byte
 
 // Exists checks if a value exists in the cache.
 func (c CacheBranchPlus) Exists(height uint64, base []byte) bool {
-	_, exists := c[strconv.Itoa(int(height))+string(base)]
+	_, exists := c[cacheKey(height, base)]
 	return exists
 }
 
 // Get returns a value that exists from the cache.
 func (c CacheBranchPlus) Get(height uint64, base []byte) []byte {
-	return c[strconv.Itoa(int(height))+string(base)]
+	return c[cacheKey(height, base)]
 }
 
 // HashCache hashes the provided values and maybe caches.
@@ -89,21 +172,37 @@ func (c CacheBranchPlus) HashCache(left, right []byte, height uint64, base, spli
 	interiorHash func(left, right []byte, height uint64, base []byte) []byte,
 	defaultHashes [][]byte) []byte {
 	h := interiorHash(left, right, height, base)
+	c.cache(h, left, right, height, base, split, defaultHashes, c.set)
+	return h
+}
+
+// Entries returns the number of entries in the cache.
+func (c CacheBranchPlus) Entries() int {
+	return len(c)
+}
+
+// Size returns an estimate of the memory used by the cache in bytes.
+func (c CacheBranchPlus) Size() int {
+	return mapSize(c)
+}
 
+func (c CacheBranchPlus) cache(h, left, right []byte, height uint64, base, split []byte,
+	defaultHashes [][]byte, set func(key string, value []byte)) {
 	if !bytes.Equal(defaultHashes[height-1], left) && !bytes.Equal(defaultHashes[height-1], right) {
-		c[strconv.Itoa(int(height-1))+string(base)] = left
-		c[strconv.Itoa(int(height-1))+string(split)] = right
+		set(cacheKey(height-1, base), left)
+		set(cacheKey(height-1, split), right)
 	} else {
-		delete(c, strconv.Itoa(int(height-1))+string(base))
-		delete(c, strconv.Itoa(int(height-1))+string(split))
+		set(cacheKey(height-1, base), nil)
+		set(cacheKey(height-1, split), nil)
 	}
+}
 
-	return h
+func (c CacheBranchPlus) set(key string, value []byte) {
+	CacheBranch(c).set(key, value)
 }
 
-// Entries returns the number of entries in the cache.
-func (c CacheBranchPlus) Entries() int {
-	return len(c)
+func (c CacheBranchPlus) has(key string) bool {
+	return CacheBranch(c).has(key)
 }
 
 // CacheBranchMinus caches every branch where both children have non-default values with the
@@ -123,13 +222,13 @@ func NewCacheBranchMinus(prob float64) *CacheBranchMinus {
 
 // Exists checks if a value exists in the cache.
 func (c CacheBranchMinus) Exists(height uint64, base []byte) bool {
-	_, exists := c.data[strconv.Itoa(int(height))+string(base)]
+	_, exists := c.data[cacheKey(height, base)]
 	return exists
 }
 
 // Get returns a value that exists from the cache.
 func (c CacheBranchMinus) Get(height uint64, base []byte) []byte {
-	return c.data[strconv.Itoa(int(height))+string(base)]
+	return c.data[cacheKey(height, base)]
 }
 
 // HashCache hashes the provided values and maybe caches.
@@ -137,12 +236,7 @@ func (c CacheBranchMinus) HashCache(left, right []byte, height uint64, base, spl
 	interiorHash func(left, right []byte, height uint64, base []byte) []byte,
 	defaultHashes [][]byte) []byte {
 	h := interiorHash(left, right, height, base)
-	if randLess(c.probability) &&
-		!bytes.Equal(defaultHashes[height-1], left) && !bytes.Equal(defaultHashes[height-1], right) {
-		c.data[strconv.Itoa(int(height))+string(base)] = h
-	} else {
-		delete(c.data, strconv.Itoa(int(height))+string(base))
-	}
+	c.cache(h, left, right, height, base, split, defaultHashes, c.set)
 	return h
 }
 
@@ -151,6 +245,29 @@ func (c CacheBranchMinus) Entries() int {
 	return len(c.data)
 }
 
+// Size returns an estimate of the memory used by the cache in bytes.
+func (c CacheBranchMinus) Size() int {
+	return mapSize(c.data)
+}
+
+func (c CacheBranchMinus) cache(h, left, right []byte, height uint64, base, split []byte,
+	defaultHashes [][]byte, set func(key string, value []byte)) {
+	if randLess(c.probability) &&
+		!bytes.Equal(defaultHashes[height-1], left) && !bytes.Equal(defaultHashes[height-1], right) {
+		set(cacheKey(height, base), h)
+	} else {
+		set(cacheKey(height, base), nil)
+	}
+}
+
+func (c CacheBranchMinus) set(key string, value []byte) {
+	CacheBranch(c.data).set(key, value)
+}
+
+func (c CacheBranchMinus) has(key string) bool {
+	return CacheBranch(c.data).has(key)
+}
+
 func randLess(x float64) bool {
 	b, err := rand.Int(rand.Reader, big.NewInt(100))
 	if err != nil {
@@ -0,0 +1,173 @@
+// Package client fetches proofs from a smtd server and verifies them locally
+// against signed roots before returning them.
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pylls/gosmt"
+)
+
+var (
+	// ErrInvalidProof is returned for proofs, or roots, that do not verify.
+	ErrInvalidProof = errors.New("invalid proof")
+	// ErrStaleRoot is returned for roots older than the last root seen.
+	ErrStaleRoot = errors.New("root older than the last root seen")
+	// ErrForkedRoot is returned for a root with the version of the last root
+	// seen but another hash: the server shows different trees to clients.
+	ErrForkedRoot = errors.New("root differs from the last root seen")
+)
+
+// Client requests proofs from a smtd server. Every proof is verified with
+// the public key of the server and the VerifyAuditPath of a SMT with the
+// same constant and hash as the server. The client tracks the last signed
+// root it saw and rejects older roots, except for explicitly requested
+// historical versions.
+type Client struct {
+	// HTTP is the client used for requests, http.DefaultClient if nil.
+	HTTP *http.Client
+
+	url  string
+	pub  ed25519.PublicKey
+	smt  *gosmt.SMT
+	mu   sync.Mutex
+	last *gosmt.SignedRoot
+}
+
+// New creates a client for the server at url, with roots signed by pub and a
+// tree using the default empty leaf constant c and hash function.
+func New(url string, pub ed25519.PublicKey, c []byte,
+	hash func(data ...[]byte) []byte) *Client {
+	return &Client{
+		url: strings.TrimSuffix(url, "/"),
+		pub: pub,
+		smt: gosmt.NewSMT(c, gosmt.CacheNothing(0), hash),
+	}
+}
+
+// Trust sets the last root seen to a root obtained out of band, e.g., from
+// an earlier session. The root must be signed by the server, and is checked
+// like any other root against the last root seen, so it cannot roll it back.
+func (c *Client) Trust(root *gosmt.SignedRoot) error {
+	if !root.Verify(c.pub) {
+		return ErrInvalidProof
+	}
+	return c.see(root, false)
+}
+
+// Last returns the last root seen, nil if none.
+func (c *Client) Last() *gosmt.SignedRoot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Root fetches and verifies the current signed root of the server.
+func (c *Client) Root() (*gosmt.SignedRoot, error) {
+	root := new(gosmt.SignedRoot)
+	if err := c.get("/root", root); err != nil {
+		return nil, err
+	}
+	if !root.Verify(c.pub) {
+		return nil, ErrInvalidProof
+	}
+	if err := c.see(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Prove fetches and verifies a proof for key against the current root of
+// the server.
+func (c *Client) Prove(key []byte) (*gosmt.Proof, error) {
+	p, err := c.prove(key, "")
+	if err != nil {
+		return nil, err
+	}
+	if err = c.see(p.Root, false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ProveAt fetches and verifies a proof for key against the root of a
+// historical version. Unlike for Prove, versions older than the last root
+// seen are accepted, while the root of the version of the last root seen
+// must match it.
+func (c *Client) ProveAt(key []byte, version uint64) (*gosmt.Proof, error) {
+	p, err := c.prove(key, "&version="+strconv.FormatUint(version, 10))
+	if err != nil {
+		return nil, err
+	}
+	if p.Root.Version != version {
+		return nil, ErrInvalidProof
+	}
+	if err = c.see(p.Root, true); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Member checks if key is in the current tree of the server.
+func (c *Client) Member(key []byte) (bool, error) {
+	p, err := c.Prove(key)
+	if err != nil {
+		return false, err
+	}
+	return p.Member(), nil
+}
+
+func (c *Client) prove(key []byte, query string) (*gosmt.Proof, error) {
+	p := new(gosmt.Proof)
+	if err := c.get("/proof?key="+hex.EncodeToString(key)+query, p); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p.Key, key) || !c.smt.VerifyProof(p, c.pub) {
+		return nil, ErrInvalidProof
+	}
+	return p, nil
+}
+
+// see checks that a verified root is not older than the last root seen,
+// unless historical, and not another root for the same version. A newer root
+// becomes the last root seen.
+func (c *Client) see(root *gosmt.SignedRoot, historical bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.last == nil || root.Version > c.last.Version:
+		c.last = root
+	case root.Version < c.last.Version:
+		if !historical {
+			return ErrStaleRoot
+		}
+	case !bytes.Equal(root.Root, c.last.Root):
+		return ErrForkedRoot
+	}
+	return nil
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	h := c.HTTP
+	if h == nil {
+		h = http.DefaultClient
+	}
+	resp, err := h.Get(c.url + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server responded %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/pylls/gosmt"
+)
+
+// server serves the proof API of smtd for fixed versions of a tree.
+type server struct {
+	smt      *gosmt.SMT
+	versions []gosmt.D
+	roots    []*gosmt.SignedRoot
+	current  int
+	tamper   bool
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v := s.current
+	if q := r.URL.Query().Get("version"); q != "" {
+		v, _ = strconv.Atoi(q)
+	}
+	switch r.URL.Path {
+	case "/root":
+		json.NewEncoder(w).Encode(s.roots[v])
+	case "/proof":
+		key, _ := hex.DecodeString(r.URL.Query().Get("key"))
+		p := s.smt.Prove(s.versions[v], key)
+		p.Root = s.roots[v]
+		if s.tamper {
+			p.Value = gosmt.Set
+		}
+		json.NewEncoder(w).Encode(p)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestClient(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := hash([]byte("a")), hash([]byte("b"))
+	s := &server{smt: gosmt.NewSMT([]byte{0x42}, gosmt.CacheNothing(0), hash)}
+	s.versions = []gosmt.D{gosmt.NewKeys(a), gosmt.NewKeys(a, b)}
+	for i, d := range s.versions {
+		s.roots = append(s.roots, gosmt.SignRoot(priv, uint64(i),
+			s.smt.RootHash(d, s.smt.N, s.smt.Base)))
+	}
+	s.current = 1
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := New(ts.URL, pub, []byte{0x42}, hash)
+	if member, err := c.Member(b); err != nil || !member {
+		t.Fatalf("expected b to be a member, got %v, %v", member, err)
+	}
+	if c.Last().Version != 1 {
+		t.Fatal("did not track the last root")
+	}
+	p, err := c.ProveAt(b, 0)
+	if err != nil || p.Member() {
+		t.Fatalf("expected b not to be a member of version 0, got %v", err)
+	}
+
+	s.tamper = true
+	if _, err = c.Prove(hash([]byte("c"))); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof for a tampered proof, got %v", err)
+	}
+	s.tamper = false
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = New(ts.URL, other, []byte{0x42}, hash).Prove(a); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof for another key, got %v", err)
+	}
+
+	s.current = 0
+	if _, err = c.Prove(a); err != ErrStaleRoot {
+		t.Fatalf("expected ErrStaleRoot, got %v", err)
+	}
+	if _, err = c.Root(); err != ErrStaleRoot {
+		t.Fatalf("expected ErrStaleRoot, got %v", err)
+	}
+
+	// a fork: another tree signed as version 1
+	s.versions[1] = gosmt.NewKeys(b)
+	s.roots[1] = gosmt.SignRoot(priv, 1,
+		s.smt.RootHash(s.versions[1], s.smt.N, s.smt.Base))
+	s.current = 1
+	if _, err = c.Prove(a); err != ErrForkedRoot {
+		t.Fatalf("expected ErrForkedRoot, got %v", err)
+	}
+	if _, err = c.ProveAt(a, 1); err != ErrForkedRoot {
+		t.Fatalf("expected ErrForkedRoot for a historical proof, got %v", err)
+	}
+	if err = c.Trust(s.roots[1]); err != ErrForkedRoot {
+		t.Fatalf("expected ErrForkedRoot for a trusted root, got %v", err)
+	}
+	if err = c.Trust(s.roots[0]); err != ErrStaleRoot || c.Last().Version != 1 {
+		t.Fatalf("trusted root rolled back the last root, got %v", err)
+	}
+}
+
+func hash(data ...[]byte) []byte {
+	hasher := sha512.New512_256()
+	for i := 0; i < len(data); i++ {
+		hasher.Write(data[i])
+	}
+	return hasher.Sum(nil)
+}
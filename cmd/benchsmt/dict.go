@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pylls/balloon/hashtreap"
+	"github.com/pylls/gosmt"
+)
+
+// dict is an authenticated dictionary of keys, so that all structures run the
+// same experiments.
+type dict interface {
+	// Update adds keys that are not in the dictionary and authenticates
+	// them, where data are the sorted keys after the update. Returns a
+	// function that restores the dictionary as it was.
+	Update(data gosmt.D, keys gosmt.Key) (undo func())
+	// Prove generates a (non-)membership proof for key.
+	Prove(key []byte)
+	// Entries returns the number of nodes stored.
+	Entries() int
+	// Size returns an estimate of the memory used in bytes.
+	Size() int
+}
+
+// structure is an authenticated dictionary to benchmark.
+type structure struct {
+	name string
+	// new creates a dictionary with the sorted keys of data.
+	new func(data gosmt.D) dict
+}
+
+// structures returns the SMT with B- caches from bMin to bMax, B and B+
+// caches, and the hash treap.
+func structures() []structure {
+	var s []structure
+	for _, prob := range probabilities() {
+		s = append(s, smtStructure(fmt.Sprintf("B-%.1f", prob), func() gosmt.Cache {
+			return gosmt.NewCacheBranchMinus(prob)
+		}))
+	}
+	return append(s,
+		smtStructure("B", func() gosmt.Cache {
+			return gosmt.CacheBranch(make(map[string][]byte))
+		}),
+		smtStructure("B+", func() gosmt.Cache {
+			return gosmt.CacheBranchPlus(make(map[string][]byte))
+		}),
+		structure{name: "HT", new: newHT})
+}
+
+// smtDict is a SMT with a cache.
+type smtDict struct {
+	s    *gosmt.SMT
+	data gosmt.D
+}
+
+func smtStructure(name string, cache func() gosmt.Cache) structure {
+	return structure{name: name, new: func(data gosmt.D) dict {
+		s := gosmt.NewSMT([]byte{0x42}, cache(), hash)
+		s.BulkLoad(data)
+		return &smtDict{s: s, data: data}
+	}}
+}
+
+func (d *smtDict) Update(data gosmt.D, keys gosmt.Key) func() {
+	old := d.data
+	d.data = data
+	d.s.Update(d.data, keys, d.s.N, d.s.Base, gosmt.Set)
+	return func() {
+		d.data = old
+		d.s.Update(d.data, keys, d.s.N, d.s.Base, gosmt.Empty)
+	}
+}
+
+func (d *smtDict) Prove(key []byte) {
+	d.s.AuditPath(d.data, d.s.N, d.s.Base, key)
+}
+
+func (d *smtDict) Entries() int { return d.s.CacheEntries() }
+
+func (d *smtDict) Size() int { return d.s.CacheSize() }
+
+// htDict is a hash treap. It is persistent, so an update is undone by
+// keeping the old version.
+type htDict struct {
+	ht *hashtreap.HashTreap
+}
+
+// htNodeSize is the size of a node in a hash treap: a key, priority and hash
+// of 32 bytes each, two pointers and a bool.
+const htNodeSize = 32*3 + 8*3
+
+func newHT(data gosmt.D) dict {
+	d := &htDict{ht: hashtreap.NewHashTreap()}
+	d.add(data)
+	return d
+}
+
+func (d *htDict) add(keys gosmt.Key) {
+	keys.Iterate(func(k []byte) bool {
+		ht, err := d.ht.Add(k, k)
+		if err != nil {
+			panic(err)
+		}
+		d.ht = ht
+		return true
+	})
+	d.ht.Update()
+}
+
+func (d *htDict) Update(data gosmt.D, keys gosmt.Key) func() {
+	old := d.ht
+	d.add(keys)
+	return func() { d.ht = old }
+}
+
+func (d *htDict) Prove(key []byte) { d.ht.MembershipQuery(key) }
+
+func (d *htDict) Entries() int { return d.ht.Size() }
+
+func (d *htDict) Size() int { return d.ht.Size() * htNodeSize }
@@ -1,289 +1,386 @@
+// Command benchsmt benchmarks authenticated dictionaries with 2^1 to 2^max
+// keys: SMTs with the caching strategies of gosmt and, for comparison, the
+// hash treap of github.com/pylls/balloon. All structures run the same
+// experiments through the dict interface. Every experiment is repeated and
+// written, with its mean and standard deviation, as one table in CSV and/or
+// JSON, and plotted as SVG charts. For example:
+//
+//	benchsmt -max 16 -exp proof,size -repeat 8 -out results
+//
+// writes results.csv, results.json, results-proof.svg and results-size.svg.
+// The charts can be regenerated from a CSV file with -plot results.csv.
 package main
 
 import (
 	"crypto/sha512"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
-	"sort"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/montanaflynn/stats"
 	"github.com/pylls/gosmt"
 )
 
-type run struct {
-	f     func(b *testing.B)
-	s     func() string
-	cache string
+// params are the parameters of a run, set by flags.
+type params struct {
+	Max             int     `json:"max"`
+	KeyUpdateDSsize int     `json:"keyUpdateDSsize"`
+	BMin            float64 `json:"bMin"`
+	BMax            float64 `json:"bMax"`
+	BDelta          float64 `json:"bDelta"`
+	UpdateSize      int     `json:"updateSize"`
+	Repeat          int     `json:"repeat"`
+	Seed            int64   `json:"seed"`
 }
 
-var (
-	maxSMT          = 20
-	keyUpdateDSsize = 15
-	bMin            = 0.5
-	bMax            = 0.9
-	bDelta          = 0.1
-	updateSize      = 256
-	filename        = "benchsmt." + time.Now().String()
-	data            []gosmt.D
-	repeat          = 4
-)
+// experiment measures one value for a structure with 2^i keys.
+type experiment struct {
+	name  string
+	title string
+	unit  string
+	run   func(i int, s structure) float64
+}
 
-type res struct {
-	index int
-	ms    float64
+// result is the outcome of repeating an experiment for one size and cache.
+type result struct {
+	Experiment string  `json:"experiment"`
+	Unit       string  `json:"unit"`
+	Size       int     `json:"size"` // the structure has 2^Size keys
+	Structure  string  `json:"structure"`
+	Mean       float64 `json:"mean"`
+	StdDev     float64 `json:"stddev"`
 }
 
+var (
+	p    params
+	data []gosmt.D // data[i] has 2^(i+1) keys
+)
+
 func main() {
+	flag.IntVar(&p.Max, "max", 20, "maximum size of SMT, as 2^max keys")
+	flag.IntVar(&p.KeyUpdateDSsize, "keysize", 15,
+		"size of the SMT, as 2^keysize keys, for the updatekeys experiment")
+	flag.Float64Var(&p.BMin, "bmin", 0.5, "smallest caching probability of B- caches")
+	flag.Float64Var(&p.BMax, "bmax", 0.9, "largest caching probability of B- caches")
+	flag.Float64Var(&p.BDelta, "bdelta", 0.1, "step between caching probabilities of B- caches")
+	flag.IntVar(&p.UpdateSize, "update", 256, "number of keys for the update experiment")
+	flag.IntVar(&p.Repeat, "repeat", 4, "number of times to repeat each measurement")
+	flag.Int64Var(&p.Seed, "seed", 1,
+		"seed for generating keys (B- caches always pick branches at random)")
+	exps := flag.String("exp", "updatekeys,update,proof,build,size,heap",
+		"comma-separated experiments to run")
+	out := flag.String("out", "benchsmt", "base name of the output files")
+	formats := flag.String("format", "csv,json,svg",
+		"comma-separated output formats: csv, json, svg (a chart per experiment)")
+	plotCSV := flag.String("plot", "",
+		"only plot the results in this CSV file as SVG charts, instead of running experiments")
 	flag.Parse()
-	if len(flag.Args()) == 0 {
-		log.Fatal("need to specify maximum size of SMT")
+	if *plotCSV != "" {
+		results, err := readCSV(*plotCSV)
+		if err == nil {
+			err = writeSVG(strings.TrimSuffix(*plotCSV, ".csv"), results)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	m, err := strconv.Atoi(flag.Arg(0))
-	if err != nil {
-		log.Fatal("the first argument has to be an int")
+	if flag.NArg() > 0 { // the maximum size used to be the only argument
+		m, err := strconv.Atoi(flag.Arg(0))
+		if err != nil {
+			log.Fatal("the first argument has to be an int")
+		}
+		p.Max = m
 	}
-	maxSMT = m
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
-	if err != nil {
-		panic(err)
+	if p.Max < 1 || p.KeyUpdateDSsize < 1 || p.Repeat < 1 || p.BDelta <= 0 {
+		log.Fatal("invalid parameters")
 	}
-	defer file.Close()
+	data = datasets(rand.New(rand.NewSource(p.Seed)), max(p.Max, p.KeyUpdateDSsize))
 
-	// generate testdata only once
-	for i := uint(1); i <= uint(maxSMT); i++ {
-		var d gosmt.D
-		size := 1 << i
-		for j := 0; j < size; j++ {
-			d = append(d, hash(randKey(make([]byte, 32))))
+	all := experiments()
+	var selected []experiment
+	for _, name := range strings.Split(*exps, ",") {
+		e, ok := all[strings.TrimSpace(name)]
+		if !ok {
+			log.Fatalf("unknown experiment %q", name)
 		}
-		sort.Sort(gosmt.D(d))
-		data = append(data, d)
+		selected = append(selected, e)
 	}
 
-	var benchAP []run
-	for i := 0; i < maxSMT; i++ {
-		for j := bMin; j <= bMax; j += bDelta {
-			benchAP = append(benchAP, run{
-				f: makeAuditPathBench(data[i],
-					gosmt.NewCacheBranchMinus(j)),
-				cache: fmt.Sprintf("B-%.1f", j),
-			})
-		}
-		benchAP = append(benchAP, run{
-			f: makeAuditPathBench(data[i],
-				gosmt.CacheBranch(make(map[string][]byte))),
-			cache: "B",
-		})
-		benchAP = append(benchAP, run{
-			f: makeAuditPathBench(data[i],
-				gosmt.CacheBranchPlus(make(map[string][]byte))),
-			cache: "B+",
-		})
+	var results []result
+	for _, e := range selected {
+		results = append(results, do(e, structures())...)
 	}
 
-	var benchUpdate []run
-	for i := 0; i < maxSMT; i++ {
-		for j := bMin; j <= bMax; j += bDelta {
-			benchUpdate = append(benchUpdate, run{
-				f:     makeUpdateBench(data[i], gosmt.NewCacheBranchMinus(j)),
-				cache: fmt.Sprintf("B-%.1f", j),
-			})
+	for _, format := range strings.Split(*formats, ",") {
+		var err error
+		switch format {
+		case "csv":
+			err = writeCSV(*out+".csv", results)
+		case "json":
+			err = writeJSON(*out+".json", results)
+		case "svg":
+			err = writeSVG(*out, results)
+		default:
+			err = fmt.Errorf("unknown format %q", format)
 		}
-		benchUpdate = append(benchUpdate, run{
-			f: makeUpdateBench(data[i],
-				gosmt.CacheBranch(make(map[string][]byte))),
-			cache: "B",
-		})
-		benchUpdate = append(benchUpdate, run{
-			f: makeUpdateBench(data[i],
-				gosmt.CacheBranchPlus(make(map[string][]byte))),
-			cache: "B+",
-		})
-	}
-
-	var benchUpdateKey []run
-	for i := 0; i < maxSMT; i++ {
-		size := 1 << uint(i+1)
-		for j := bMin; j <= bMax; j += bDelta {
-			benchUpdateKey = append(benchUpdateKey, run{
-				f: makeUpdateKeyBench(size, data[keyUpdateDSsize-1],
-					gosmt.NewCacheBranchMinus(j)),
-				cache: fmt.Sprintf("B-%.1f", j),
-			})
+		if err != nil {
+			log.Fatal(err)
 		}
-		benchUpdateKey = append(benchUpdateKey, run{
-			f: makeUpdateKeyBench(size, data[keyUpdateDSsize-1],
-				gosmt.CacheBranch(make(map[string][]byte))),
-			cache: "B",
-		})
-		benchUpdateKey = append(benchUpdateKey, run{
-			f: makeUpdateKeyBench(size, data[keyUpdateDSsize-1],
-				gosmt.CacheBranchPlus(make(map[string][]byte))),
-			cache: "B+",
-		})
 	}
+}
 
-	var benchCacheSize []run
-	for i := 0; i < maxSMT; i++ {
-		for j := bMin; j <= bMax; j += bDelta {
-			benchCacheSize = append(benchCacheSize, run{
-				s: makeCacheSizeBench(data[i],
-					gosmt.NewCacheBranchMinus(j)),
-				cache: fmt.Sprintf("B-%.1f", j),
-			})
-		}
-		benchCacheSize = append(benchCacheSize, run{
-			s: makeCacheSizeBench(data[i],
-				gosmt.CacheBranch(make(map[string][]byte))),
-			cache: "B",
-		})
-		benchCacheSize = append(benchCacheSize, run{
-			s: makeCacheSizeBench(data[i],
-				gosmt.CacheBranchPlus(make(map[string][]byte))),
-			cache: "B+",
-		})
+func experiments() map[string]experiment {
+	return map[string]experiment{
+		"updatekeys": {
+			name: "updatekeys",
+			title: fmt.Sprintf("update time for 2^i keys in 2^%d structure",
+				p.KeyUpdateDSsize),
+			unit: "ms",
+			run: func(i int, s structure) float64 {
+				return ms(makeUpdateBench(dataset(p.KeyUpdateDSsize), 1<<uint(i), s))
+			},
+		},
+		"update": {
+			name:  "update",
+			title: fmt.Sprintf("update time for %d keys", p.UpdateSize),
+			unit:  "ms",
+			run: func(i int, s structure) float64 {
+				return ms(makeUpdateBench(dataset(i), p.UpdateSize, s))
+			},
+		},
+		"proof": {
+			name:  "proof",
+			title: "proof generation time",
+			unit:  "ms",
+			run: func(i int, s structure) float64 {
+				return ms(makeProofBench(dataset(i), s))
+			},
+		},
+		"build": {
+			name:  "build",
+			title: "build time",
+			unit:  "ms",
+			run: func(i int, s structure) float64 {
+				return ms(makeBuildBench(dataset(i), s))
+			},
+		},
+		"size": {
+			name:  "size",
+			title: "size as estimated by the structure",
+			unit:  "MiB",
+			run: func(i int, s structure) float64 {
+				return float64(s.new(dataset(i)).Size()) / float64(1024*1024)
+			},
+		},
+		"entries": {
+			name:  "entries",
+			title: "stored nodes",
+			unit:  "nodes",
+			run: func(i int, s structure) float64 {
+				return float64(s.new(dataset(i)).Entries())
+			},
+		},
+		"heap": {
+			name:  "heap",
+			title: "heap used by the structure",
+			unit:  "MiB",
+			run: func(i int, s structure) float64 {
+				return heapSize(dataset(i), s)
+			},
+		},
 	}
+}
 
-	do(fmt.Sprintf("update time (ms) for 2^i keys in 2^%d SMT", keyUpdateDSsize),
-		benchUpdateKey, file)
-	do(fmt.Sprintf("update time (ms) for %d keys", updateSize), benchUpdate, file)
-	do("cache size (MiB)", benchCacheSize, file)
-	do("audit path generation time (ms)", benchAP, file)
-
+// probabilities returns the caching probabilities from bMin to bMax.
+func probabilities() []float64 {
+	var probs []float64
+	steps := int(math.Round((p.BMax - p.BMin) / p.BDelta))
+	for k := 0; k <= steps; k++ {
+		probs = append(probs, p.BMin+float64(k)*p.BDelta)
+	}
+	return probs
 }
 
-func do(exp string, bench []run, file *os.File) {
-	flog(fmt.Sprintf("####### experiment: %s #######", exp), file)
-	expCount := len(bench) / maxSMT
-	header := "SMT size 2^x"
-	for i := 0; i < expCount; i++ {
-		header += fmt.Sprintf(", %s", bench[i].cache)
+// do runs an experiment for all sizes and structures, logging a table of
+// means.
+func do(e experiment, structures []structure) []result {
+	log.Printf("####### experiment: %s (%s) #######", e.title, e.unit)
+	header := "size 2^x"
+	for _, s := range structures {
+		header += fmt.Sprintf(", %s", s.name)
 	}
-	flog(header, file)
+	log.Println(header)
 
-	for i := 0; i < maxSMT; i++ {
-		r := fmt.Sprintf("%d", i+1)
-		for j := 0; j < expCount; j++ {
-			if bench[i].s != nil {
-				r += fmt.Sprintf(", %s", bench[i*expCount+j].s())
-			} else {
-				results := make([]float64, repeat)
-				for round := 0; round < repeat; round++ {
-					results[round] = float64(testing.Benchmark(bench[i*expCount+j].f).NsPerOp()) / float64(1000*1000) // ns to ms
-				}
-				avg, err := stats.LoadRawData(results).Mean()
-				if err != nil {
-					panic(err)
-				}
-				r += fmt.Sprintf(", %.4f", avg)
+	var results []result
+	for i := 1; i <= p.Max; i++ {
+		row := fmt.Sprintf("%d", i)
+		for _, s := range structures {
+			samples := make([]float64, p.Repeat)
+			for round := range samples {
+				samples[round] = e.run(i, s)
+			}
+			r := result{Experiment: e.name, Unit: e.unit, Size: i, Structure: s.name}
+			var err error
+			if r.Mean, err = stats.Mean(samples); err != nil {
+				panic(err)
 			}
+			if r.StdDev, err = stats.StandardDeviation(samples); err != nil {
+				panic(err)
+			}
+			results = append(results, r)
+			row += fmt.Sprintf(", %.4f", r.Mean)
 		}
-		flog(r, file)
+		log.Println(row)
 	}
+	return results
 }
 
-func flog(str string, f *os.File) {
-	_, err := f.WriteString(str + "\n")
+func writeCSV(name string, results []result) error {
+	f, err := os.Create(name)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"experiment", "unit", "size", "structure", "mean", "stddev"})
+	for _, r := range results {
+		w.Write([]string{r.Experiment, r.Unit, strconv.Itoa(r.Size), r.Structure,
+			strconv.FormatFloat(r.Mean, 'f', -1, 64),
+			strconv.FormatFloat(r.StdDev, 'f', -1, 64)})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
 	}
-	log.Println(str)
+	return f.Close()
 }
 
-func makeAuditPathBench(data gosmt.D,
-	cache gosmt.Cache) func(b *testing.B) {
-	return func(b *testing.B) {
-		s := gosmt.NewSMT([]byte{0x42}, cache, hash)
-		s.Update(data, gosmt.Key(data), s.N, s.Base, gosmt.Set)
-
-		// create N keys
-		keys := make([][]byte, b.N)
-		for i := 0; i < b.N; i++ {
-			keys[i] = randKey(make([]byte, s.N/8))
-		}
+func writeJSON(name string, results []result) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(struct {
+		Params  params   `json:"params"`
+		Results []result `json:"results"`
+	}{p, results}); err != nil {
+		return err
+	}
+	return f.Close()
+}
 
-		b.ResetTimer()
-		for i := 0; i < b.N; i++ {
-			s.AuditPath(data, s.N, s.Base, keys[i])
+// datasets generates sorted data with 2^1 to 2^n keys from rng, all up
+// front so the data only depends on the seed, not on the experiments run.
+func datasets(rng *rand.Rand, n int) []gosmt.D {
+	data := make([]gosmt.D, n)
+	for i := range data {
+		keys := make([][]byte, 1<<uint(i+1))
+		for j := range keys {
+			keys[j] = hash(randKey(rng, make([]byte, 32)))
 		}
+		data[i] = gosmt.NewKeys(keys...)
 	}
+	return data
+}
+
+// dataset returns the sorted data with 2^i keys.
+func dataset(i int) gosmt.D {
+	return data[i-1]
 }
 
-func makeUpdateBench(data gosmt.D,
-	cache gosmt.Cache) func(b *testing.B) {
+// keyRNG returns the source of the keys a benchmark proves or updates. It is
+// separate from the source of the data and seeded anew for every run, so the
+// keys do not depend on b.N of earlier runs or benchmarks.
+func keyRNG() *rand.Rand {
+	return rand.New(rand.NewSource(p.Seed + 1))
+}
+
+// ms runs a benchmark and returns the time per operation in ms.
+func ms(f func(b *testing.B)) float64 {
+	return float64(testing.Benchmark(f).NsPerOp()) / float64(1000*1000)
+}
+
+func makeProofBench(data gosmt.D, s structure) func(b *testing.B) {
 	return func(b *testing.B) {
-		s := gosmt.NewSMT([]byte{0x42}, cache, hash)
-		s.Update(data, gosmt.Key(data), s.N, s.Base, gosmt.Set)
+		d := s.new(data)
 
-		// create updateSize keys
-		keys := make([][]byte, updateSize)
-		for i := 0; i < updateSize; i++ {
-			keys[i] = randKey(make([]byte, s.N/8))
+		// create N keys
+		rng := keyRNG()
+		keys := make([][]byte, b.N)
+		for i := 0; i < b.N; i++ {
+			keys[i] = randKey(rng, make([]byte, 32))
 		}
-		newdata := make([][]byte, len(data), len(data)+len(keys))
-		copy(newdata, data)
-		newdata = append(newdata, keys...)
-		sort.Sort(gosmt.D(newdata))
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			s.Update(newdata, keys, s.N, s.Base, gosmt.Set)
-			b.StopTimer()
-			s.Update(data, keys, s.N, s.Base, gosmt.Empty)
-			b.StartTimer()
+			d.Prove(keys[i])
 		}
 	}
 }
 
-func makeUpdateKeyBench(size int, data gosmt.D,
-	cache gosmt.Cache) func(b *testing.B) {
+func makeUpdateBench(data gosmt.D, size int,
+	s structure) func(b *testing.B) {
 	return func(b *testing.B) {
-		s := gosmt.NewSMT([]byte{0x42}, cache, hash)
-		s.Update(data, gosmt.Key(data), s.N, s.Base, gosmt.Set)
+		d := s.new(data)
+		rng := keyRNG()
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			b.StopTimer()
-
-			keys := make([][]byte, size)
+			fresh := make([][]byte, size)
 			for i := 0; i < size; i++ {
-				keys[i] = randKey(make([]byte, s.N/8))
+				fresh[i] = randKey(rng, make([]byte, 32))
 			}
-			newdata := make([][]byte, len(data), len(data)+len(keys))
-			copy(newdata, data)
-			newdata = append(newdata, keys...)
-			sort.Sort(gosmt.D(newdata))
+			keys := gosmt.NewKeys(fresh...)
+			newdata := data.Insert(fresh...)
 
 			b.StartTimer()
-			s.Update(newdata, keys, s.N, s.Base, gosmt.Set)
+			undo := d.Update(newdata, keys)
 
 			b.StopTimer()
 			// cleanup, remove the keys we just inserted
-			s.Update(data, keys, s.N, s.Base, gosmt.Empty)
+			undo()
 			b.StartTimer()
 		}
 	}
 }
 
-func makeCacheSizeBench(data gosmt.D,
-	cache gosmt.Cache) func() string {
-	return func() string {
-		s := gosmt.NewSMT([]byte{0x42}, cache, hash)
-		s.Update(data, gosmt.Key(data), s.N, s.Base, gosmt.Set)
-
-		return fmt.Sprintf("%.4f",
-			float64(s.CacheEntries()*int(s.N))/float64(1024*1024))
+func makeBuildBench(data gosmt.D, s structure) func(b *testing.B) {
+	return func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.new(data)
+		}
 	}
 }
 
-func randKey(key []byte) []byte {
-	_, err := rand.Read(key)
+// heapSize returns the live heap, in MiB, allocated by creating the
+// structure with data, measured with forced garbage collections.
+func heapSize(data gosmt.D, s structure) float64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	d := s.new(data)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(d)
+	return float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)) / float64(1024*1024)
+}
+
+func randKey(rng *rand.Rand, key []byte) []byte {
+	_, err := rng.Read(key)
 	if err != nil {
 		panic(err)
 	}
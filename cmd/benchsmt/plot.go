@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// chart dimensions in pixels
+const (
+	chartWidth   = 720
+	chartHeight  = 420
+	marginLeft   = 80
+	marginRight  = 110 // room for the legend
+	marginTop    = 40
+	marginBottom = 50
+)
+
+// colors of the series in a chart
+var colors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// writeSVG writes a chart for each experiment in results to base-<name>.svg.
+func writeSVG(base string, results []result) error {
+	var names []string
+	byExperiment := make(map[string][]result)
+	for _, r := range results {
+		if _, ok := byExperiment[r.Experiment]; !ok {
+			names = append(names, r.Experiment)
+		}
+		byExperiment[r.Experiment] = append(byExperiment[r.Experiment], r)
+	}
+
+	all := experiments()
+	for _, name := range names {
+		title := name
+		if e, ok := all[name]; ok {
+			title = e.title
+		}
+		f, err := os.Create(base + "-" + name + ".svg")
+		if err != nil {
+			return err
+		}
+		if err = plot(f, title, byExperiment[name]); err != nil {
+			f.Close()
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plot writes a SVG line chart of the mean, with the standard deviation as
+// error bars, against the size for each structure in results.
+func plot(w io.Writer, title string, results []result) error {
+	if len(results) == 0 {
+		return errors.New("nothing to plot")
+	}
+	var structures []string
+	series := make(map[string][]result)
+	minX, maxX, maxY := results[0].Size, results[0].Size, 0.0
+	for _, r := range results {
+		if _, ok := series[r.Structure]; !ok {
+			structures = append(structures, r.Structure)
+		}
+		series[r.Structure] = append(series[r.Structure], r)
+		minX, maxX = min(minX, r.Size), max(maxX, r.Size)
+		maxY = math.Max(maxY, r.Mean+r.StdDev)
+	}
+	if minX == maxX {
+		maxX++
+	}
+	step := tickStep(maxY)
+	maxY = math.Ceil(maxY/step) * step
+	if maxY == 0 { // all zero, e.g., no cache entries, still one tick high
+		maxY = step
+	}
+
+	plotW := float64(chartWidth - marginLeft - marginRight)
+	plotH := float64(chartHeight - marginTop - marginBottom)
+	x := func(size int) float64 {
+		return marginLeft + float64(size-minX)/float64(maxX-minX)*plotW
+	}
+	y := func(v float64) float64 {
+		return marginTop + plotH - v/maxY*plotH
+	}
+
+	ew := &errWriter{w: w}
+	ew.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" `+
+		`font-family="sans-serif" font-size="12">`+"\n", chartWidth, chartHeight)
+	ew.printf(`<rect width="100%%" height="100%%" fill="white"/>` + "\n")
+	ew.printf(`<text x="%d" y="%d" text-anchor="middle" font-size="14">%s (%s)</text>`+"\n",
+		chartWidth/2, marginTop/2+5, html.EscapeString(title), html.EscapeString(results[0].Unit))
+
+	// axes, grid and ticks
+	ew.printf(`<g stroke="#ccc">` + "\n")
+	for v := 0.0; v <= maxY+step/2; v += step {
+		ew.printf(`<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f"/>`+"\n",
+			marginLeft, y(v), marginLeft+plotW, y(v))
+	}
+	ew.printf("</g>\n")
+	ew.printf(`<g stroke="black"><line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f"/>`+
+		`<line x1="%d" y1="%d" x2="%d" y2="%.1f"/></g>`+"\n",
+		marginLeft, y(0), marginLeft+plotW, y(0),
+		marginLeft, marginTop, marginLeft, y(0))
+	for v := 0.0; v <= maxY+step/2; v += step {
+		ew.printf(`<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n",
+			marginLeft-6, y(v)+4, strconv.FormatFloat(v, 'g', 4, 64))
+	}
+	for size := minX; size <= maxX; size++ {
+		ew.printf(`<text x="%.1f" y="%.1f" text-anchor="middle">%d</text>`+"\n",
+			x(size), y(0)+18, size)
+	}
+	ew.printf(`<text x="%.1f" y="%d" text-anchor="middle">size (2^x keys)</text>`+"\n",
+		marginLeft+plotW/2, chartHeight-8)
+
+	// series and legend
+	for i, name := range structures {
+		color := colors[i%len(colors)]
+		ew.printf(`<g stroke="%s" fill="%s">`+"\n", color, color)
+		ew.printf(`<polyline fill="none" stroke-width="2" points="`)
+		for _, r := range series[name] {
+			ew.printf("%.1f,%.1f ", x(r.Size), y(r.Mean))
+		}
+		ew.printf(`"/>` + "\n")
+		for _, r := range series[name] {
+			if r.StdDev > 0 {
+				ew.printf(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`+"\n",
+					x(r.Size), y(r.Mean-r.StdDev), x(r.Size), y(r.Mean+r.StdDev))
+			}
+			ew.printf(`<circle cx="%.1f" cy="%.1f" r="2.5"/>`+"\n", x(r.Size), y(r.Mean))
+		}
+		ly := marginTop + 10 + 18*i
+		ew.printf(`<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke-width="2"/>`+"\n",
+			marginLeft+plotW+12, ly, marginLeft+plotW+32, ly)
+		ew.printf(`<text x="%.1f" y="%d" stroke="none" fill="black">%s</text>`+"\n",
+			marginLeft+plotW+38, ly+4, html.EscapeString(name))
+		ew.printf("</g>\n")
+	}
+	ew.printf("</svg>\n")
+	return ew.err
+}
+
+// tickStep returns a step of 1, 2 or 5 times a power of ten that splits
+// [0, max] into at most 10 ticks.
+func tickStep(max float64) float64 {
+	if max <= 0 {
+		return 1
+	}
+	pow := math.Pow(10, math.Floor(math.Log10(max/10)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if max/(m*pow) <= 10 {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+// readCSV reads results written by writeCSV.
+func readCSV(name string) ([]result, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty CSV")
+	}
+
+	var results []result
+	for i, rec := range records[1:] { // skip the header
+		if len(rec) != 6 {
+			return nil, fmt.Errorf("line %d: expected 6 fields", i+2)
+		}
+		r := result{Experiment: rec[0], Unit: rec[1], Structure: rec[3]}
+		var err1, err2, err3 error
+		r.Size, err1 = strconv.Atoi(rec[2])
+		r.Mean, err2 = strconv.ParseFloat(rec[4], 64)
+		r.StdDev, err3 = strconv.ParseFloat(rec[5], 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("line %d: invalid number", i+2)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// errWriter keeps the first error of a sequence of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, a...)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlot(t *testing.T) {
+	results := []result{
+		{Experiment: "proof", Unit: "ms", Size: 1, Structure: "B", Mean: 0.5, StdDev: 0.1},
+		{Experiment: "proof", Unit: "ms", Size: 2, Structure: "B", Mean: 0.7},
+		{Experiment: "proof", Unit: "ms", Size: 1, Structure: "HT<", Mean: 0.2},
+		{Experiment: "proof", Unit: "ms", Size: 2, Structure: "HT<", Mean: 1.3},
+	}
+
+	// the results survive a round trip through CSV
+	name := filepath.Join(t.TempDir(), "results.csv")
+	if err := writeCSV(name, results); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readCSV(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, results) {
+		t.Fatalf("read %v, expected %v", read, results)
+	}
+
+	var buf bytes.Buffer
+	if err = plot(&buf, "proof generation time", results); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	if strings.Count(svg, "<polyline") != 2 || !strings.Contains(svg, "HT&lt;") {
+		t.Fatal("missing series in chart")
+	}
+	// the chart is well-formed XML
+	dec := xml.NewDecoder(&buf)
+	for {
+		if _, err = dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v", err)
+		}
+	}
+}
+
+func TestPlotZero(t *testing.T) {
+	var buf bytes.Buffer
+	if err := plot(&buf, "stored nodes", []result{
+		{Experiment: "entries", Unit: "nodes", Size: 1, Structure: "none"},
+		{Experiment: "entries", Unit: "nodes", Size: 2, Structure: "none"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "NaN") {
+		t.Fatal("NaN in chart of zeros")
+	}
+}
+
+func TestTickStep(t *testing.T) {
+	for max, step := range map[float64]float64{
+		0: 1, 1: 0.1, 1.5: 0.2, 4: 0.5, 10: 1, 37: 5, 100: 10, 0.003: 0.0005,
+	} {
+		if got := tickStep(max); math.Abs(got-step) > step*1e-9 {
+			t.Fatalf("tickStep(%g) = %g, expected %g", max, got, step)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Command smt builds, proves and verifies sparse Merkle trees from key files.
+//
+// Usage:
+//
+//	smt build  [-hex | -sorted] [file]          print the root of the keys
+//	smt prove  [-hex] -key k [file]             print a proof for key k
+//	smt verify -root r | -pub p [proof]         verify a proof
+//	smt stats  [-hex] [-cache c] [file]         print cache size and depth
+//
+// Key files contain concatenated binary keys, as written by gosmt.WriteKeys,
+// or with -hex one hex-encoded key per line. Without a file, keys and proofs
+// are read from stdin. Proofs are JSON-encoded gosmt.Proof. The tree uses
+// SHA-512/256 and the same default empty leaf constant as smtd.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pylls/gosmt"
+)
+
+var c = []byte{0x42}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch args := os.Args[2:]; os.Args[1] {
+	case "build":
+		err = build(args, os.Stdout)
+	case "prove":
+		err = prove(args, os.Stdout)
+	case "verify":
+		err = verify(args, os.Stdout)
+	case "stats":
+		err = stats(args, os.Stdout)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "smt:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: smt build|prove|verify|stats [flags] [file]")
+	os.Exit(2)
+}
+
+func build(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	hexKeys := fs.Bool("hex", false, "keys are hex-encoded, one per line")
+	sorted := fs.Bool("sorted", false,
+		"binary keys are in strictly ascending order, stream instead of loading them")
+	fs.Parse(args)
+	if *sorted && *hexKeys {
+		return errors.New("-sorted needs binary keys, not -hex")
+	}
+
+	in, err := open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	s := gosmt.NewSMT(c, gosmt.CacheNothing(0), hash)
+
+	var root []byte
+	if *sorted {
+		if root, err = s.RootHashFromReader(in, nil); err != nil {
+			return err
+		}
+	} else {
+		d, err := readKeys(in, *hexKeys, s.N/8)
+		if err != nil {
+			return err
+		}
+		root = s.RootHash(d, s.N, s.Base)
+	}
+	fmt.Fprintf(out, "%x\n", root)
+	return nil
+}
+
+func prove(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("prove", flag.ExitOnError)
+	hexKeys := fs.Bool("hex", false, "keys are hex-encoded, one per line")
+	key := fs.String("key", "", "hex-encoded key to prove")
+	fs.Parse(args)
+
+	k, err := hex.DecodeString(*key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %v", err)
+	}
+	in, err := open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	s := gosmt.NewSMT(c, gosmt.CacheBranch(make(map[string][]byte)), hash)
+	if uint64(len(k)) != s.N/8 {
+		return errors.New("invalid key length")
+	}
+	d, err := readKeys(in, *hexKeys, s.N/8)
+	if err != nil {
+		return err
+	}
+
+	root := s.BulkLoad(d)
+	p := s.Prove(d, k)
+	p.Root = &gosmt.SignedRoot{Root: root}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func verify(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	root := fs.String("root", "", "hex-encoded root to verify the proof against")
+	pub := fs.String("pub", "", "hex-encoded public key that signed the root of the proof")
+	fs.Parse(args)
+
+	in, err := open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	p := new(gosmt.Proof)
+	if err = json.NewDecoder(in).Decode(p); err != nil {
+		return fmt.Errorf("invalid proof: %v", err)
+	}
+	if p.Root == nil {
+		return errors.New("proof without root")
+	}
+	s := gosmt.NewSMT(c, gosmt.CacheNothing(0), hash)
+	if uint64(len(p.Key)) != s.N/8 {
+		return fmt.Errorf("invalid proof: key of %d bytes", len(p.Key))
+	}
+
+	var valid bool
+	switch {
+	case *pub != "":
+		pk, err := hex.DecodeString(*pub)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %v", err)
+		}
+		valid = s.VerifyProof(p, ed25519.PublicKey(pk))
+	case *root != "":
+		r, err := hex.DecodeString(*root)
+		if err != nil {
+			return fmt.Errorf("invalid root: %v", err)
+		}
+		valid = bytes.Equal(p.Root.Root, r) &&
+			s.VerifyAuditPath(p.AuditPath, p.Key, p.Value, r)
+	default:
+		return errors.New("need -root or -pub to verify against")
+	}
+	if !valid {
+		return errors.New("invalid proof")
+	}
+	if p.Member() {
+		fmt.Fprintf(out, "valid: %x is a member\n", p.Key)
+	} else {
+		fmt.Fprintf(out, "valid: %x is not a member\n", p.Key)
+	}
+	return nil
+}
+
+func stats(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	hexKeys := fs.Bool("hex", false, "keys are hex-encoded, one per line")
+	cacheName := fs.String("cache", "B", "caching strategy: none, B, B+ or B-<p>, e.g., B-0.5")
+	fs.Parse(args)
+
+	cache, err := parseCache(*cacheName)
+	if err != nil {
+		return err
+	}
+	in, err := open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	s := gosmt.NewSMT(c, cache, hash)
+	d, err := readKeys(in, *hexKeys, s.N/8)
+	if err != nil {
+		return err
+	}
+
+	root := s.BulkLoad(d)
+	min, max, mean := depth(d)
+	fmt.Fprintf(out, "root:          %x\n", root)
+	fmt.Fprintf(out, "keys:          %d\n", d.Len())
+	fmt.Fprintf(out, "cache entries: %d (%s)\n", s.CacheEntries(), *cacheName)
+	fmt.Fprintf(out, "cache size:    %d bytes (estimated)\n", s.CacheSize())
+	fmt.Fprintf(out, "leaf depth:    min %d, max %d, mean %.2f\n", min, max, mean)
+	return nil
+}
+
+// depth returns the minimum, maximum and mean depth of the leaves of the
+// sorted keys d, where the depth of a leaf is the length of the path from
+// the root to the first node whose subtree has no other keys.
+func depth(keys gosmt.D) (min, max int, mean float64) {
+	d := keys.All()
+	if len(d) == 0 {
+		return 0, 0, 0
+	}
+	min = -1
+	var sum int
+	for i := range d {
+		n := 0
+		if i > 0 {
+			n = commonPrefix(d[i-1], d[i])
+		}
+		if i < len(d)-1 {
+			n = maxInt(n, commonPrefix(d[i], d[i+1]))
+		}
+		if len(d) > 1 {
+			n++ // one more than the bits shared with the closest key
+		}
+		if min < 0 || n < min {
+			min = n
+		}
+		max = maxInt(max, n)
+		sum += n
+	}
+	return min, max, float64(sum) / float64(len(d))
+}
+
+// commonPrefix returns the number of leading bits a and b have in common.
+func commonPrefix(a, b []byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			n := i * 8
+			for x&0x80 == 0 {
+				x <<= 1
+				n++
+			}
+			return n
+		}
+	}
+	return len(a) * 8
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func parseCache(name string) (gosmt.Cache, error) {
+	switch {
+	case name == "none":
+		return gosmt.CacheNothing(0), nil
+	case name == "B":
+		return gosmt.CacheBranch(make(map[string][]byte)), nil
+	case name == "B+":
+		return gosmt.CacheBranchPlus(make(map[string][]byte)), nil
+	case strings.HasPrefix(name, "B-"):
+		var p float64
+		if _, err := fmt.Sscanf(name, "B-%g", &p); err != nil || p < 0 || p > 1 {
+			return nil, errors.New("invalid probability in cache " + name)
+		}
+		return gosmt.NewCacheBranchMinus(p), nil
+	}
+	return nil, errors.New("unknown cache " + name)
+}
+
+// readKeys reads all keys of size bytes from r, binary or hex-encoded one
+// per line, and returns them sorted.
+func readKeys(r io.Reader, hexKeys bool, size uint64) (gosmt.D, error) {
+	var keys [][]byte
+	if hexKeys {
+		sc := bufio.NewScanner(r)
+		for line := 1; sc.Scan(); line++ {
+			text := strings.TrimSpace(sc.Text())
+			if text == "" {
+				continue
+			}
+			k, err := hex.DecodeString(text)
+			if err != nil || uint64(len(k)) != size {
+				return gosmt.D{}, fmt.Errorf("invalid key on line %d", line)
+			}
+			keys = append(keys, k)
+		}
+		if err := sc.Err(); err != nil {
+			return gosmt.D{}, err
+		}
+	} else {
+		br := bufio.NewReader(r)
+		for {
+			k := make([]byte, size)
+			if _, err := io.ReadFull(br, k); err == io.EOF {
+				break
+			} else if err != nil {
+				return gosmt.D{}, errors.New("truncated key file")
+			}
+			keys = append(keys, k)
+		}
+	}
+	return gosmt.NewKeys(keys...), nil
+}
+
+// open opens the named file, or stdin for no name or "-".
+func open(name string) (io.ReadCloser, error) {
+	if name == "" || name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+func hash(data ...[]byte) []byte {
+	hasher := sha512.New512_256()
+	for i := 0; i < len(data); i++ {
+		hasher.Write(data[i])
+	}
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pylls/gosmt"
+)
+
+func TestReadKeys(t *testing.T) {
+	keys := gosmt.NewKeys(hash([]byte("a")), hash([]byte("b")), hash([]byte("c")))
+	var bin bytes.Buffer
+	if err := gosmt.WriteKeys(&bin, keys); err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	for i := keys.Len() - 1; i >= 0; i-- {
+		text.WriteString(hex.EncodeToString(keys.At(i)) + "\n\n")
+	}
+
+	s := gosmt.NewSMT(c, gosmt.CacheNothing(0), hash)
+	streamed, err := s.RootHashFromReader(bytes.NewReader(bin.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hexKeys := range []bool{false, true} {
+		in := bytes.NewReader(bin.Bytes())
+		if hexKeys {
+			in = bytes.NewReader([]byte(text.String()))
+		}
+		d, err := readKeys(in, hexKeys, s.N/8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(s.RootHash(d, s.N, s.Base), streamed) {
+			t.Fatalf("unexpected root for keys read with hex %v", hexKeys)
+		}
+	}
+
+	if _, err = readKeys(bytes.NewReader(bin.Bytes()[1:]), false, s.N/8); err == nil {
+		t.Fatal("read truncated key file")
+	}
+	if _, err = readKeys(strings.NewReader("abcd\n"), true, s.N/8); err == nil {
+		t.Fatal("read key of invalid length")
+	}
+}
+
+func TestProveVerify(t *testing.T) {
+	dir := t.TempDir()
+	keys := gosmt.NewKeys(hash([]byte("a")), hash([]byte("b")), hash([]byte("c")))
+	var text strings.Builder
+	for _, k := range keys.All() {
+		text.WriteString(hex.EncodeToString(k) + "\n")
+	}
+	keyPath := filepath.Join(dir, "keys")
+	if err := os.WriteFile(keyPath, []byte(text.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := build([]string{"-hex", keyPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	root := strings.TrimSpace(out.String())
+	if build([]string{"-hex", "-sorted", keyPath}, &out) == nil {
+		t.Fatal("built from hex keys with -sorted")
+	}
+
+	for _, key := range [][]byte{keys.At(1), hash([]byte("d"))} {
+		out.Reset()
+		if err := prove([]string{"-hex", "-key", hex.EncodeToString(key), keyPath},
+			&out); err != nil {
+			t.Fatal(err)
+		}
+		proofPath := filepath.Join(dir, "proof")
+		if err := os.WriteFile(proofPath, out.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if err := verify([]string{"-root", root, proofPath}, &out); err != nil {
+			t.Fatal(err)
+		}
+		member := !strings.Contains(out.String(), "not a member")
+		if member != bytes.Equal(key, keys.At(1)) {
+			t.Fatalf("wrong membership: %s", out.String())
+		}
+
+		wrong := hex.EncodeToString(hash([]byte("wrong root")))
+		if verify([]string{"-root", wrong, proofPath}, &out) == nil {
+			t.Fatal("verified proof against a wrong root")
+		}
+
+		// tamper with the audit path of the proof
+		var p gosmt.Proof
+		data, _ := os.ReadFile(proofPath)
+		if err := json.Unmarshal(data, &p); err != nil {
+			t.Fatal(err)
+		}
+		p.AuditPath[0][0] ^= 1
+		if data, err := json.Marshal(p); err != nil {
+			t.Fatal(err)
+		} else if err = os.WriteFile(proofPath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if verify([]string{"-root", root, proofPath}, &out) == nil {
+			t.Fatal("verified tampered proof")
+		}
+
+		// a malformed proof, with a short key, is an error and not a panic
+		p.Key = p.Key[:1]
+		if data, err := json.Marshal(p); err != nil {
+			t.Fatal(err)
+		} else if err = os.WriteFile(proofPath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if verify([]string{"-root", root, proofPath}, &out) == nil {
+			t.Fatal("verified proof with a short key")
+		}
+	}
+}
+
+func TestDepth(t *testing.T) {
+	k := func(b byte) []byte { return []byte{b, 0} }
+	for _, tc := range []struct {
+		d        gosmt.D
+		min, max int
+		mean     float64
+	}{
+		{gosmt.D{}, 0, 0, 0},
+		{gosmt.NewKeys(k(0x00)), 0, 0, 0},
+		{gosmt.NewKeys(k(0x00), k(0x80)), 1, 1, 1},
+		{gosmt.NewKeys(k(0x00), k(0x40), k(0x80)), 1, 2, 5.0 / 3},
+	} {
+		min, max, mean := depth(tc.d)
+		if min != tc.min || max != tc.max || mean != tc.mean {
+			t.Fatalf("depth of %x: got %d, %d, %f", tc.d.All(), min, max, mean)
+		}
+	}
+}
+
+func TestParseCache(t *testing.T) {
+	for _, name := range []string{"none", "B", "B+", "B-0.5"} {
+		if _, err := parseCache(name); err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "B-", "B-2", "C"} {
+		if _, err := parseCache(name); err == nil {
+			t.Fatalf("parsed invalid cache %q", name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Command smtd serves a sparse Merkle tree over a local HTTP/JSON API.
+//
+// Endpoints:
+//
+//	POST /update            apply a batch {"keys": [...], "remove": false}
+//	GET  /root[?version=n]  the signed root of the current or a historical version
+//	GET  /proof?key=k[&version=n]
+//	                        a (non-)membership proof for the hex-encoded key k
+//
+// Keys and hashes in JSON are base64 encoded byte strings. Roots are signed
+// with an Ed25519 key, whose public key is logged on start.
+//
+// With -wal, updates are logged and replayed on restart. Without it, the tree
+// and its versions start over at version 0 on every restart, so clients that
+// saw an earlier root report client.ErrStaleRoot or client.ErrForkedRoot.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	keyPath := flag.String("key", "smtd.key",
+		"file with the hex-encoded Ed25519 seed to sign roots with, created if missing")
+	walPath := flag.String("wal", "",
+		"write-ahead log of updates, none if empty (versions then restart at 0)")
+	history := flag.Int("history", 16,
+		"number of historical versions to keep, proofs for older versions hash all keys")
+	flag.Parse()
+
+	priv, err := loadKey(*keyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("public key: %x", priv.Public())
+
+	srv := newServer(priv, *history)
+	if *walPath != "" {
+		if err = srv.openWAL(*walPath); err != nil {
+			log.Fatal(err)
+		}
+		defer srv.wal.Close()
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
+
+// loadKey loads the private key from the seed at path, or generates and
+// stores a new one if there is no file.
+func loadKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err = rand.Read(seed); err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(path, []byte(hex.EncodeToString(seed)+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid key file " + path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func hash(data ...[]byte) []byte {
+	hasher := sha512.New512_256()
+	for i := 0; i < len(data); i++ {
+		hasher.Write(data[i])
+	}
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pylls/gosmt"
+)
+
+// updateRequest is the body of POST /update.
+type updateRequest struct {
+	Keys   [][]byte `json:"keys"`
+	Remove bool     `json:"remove"`
+}
+
+// version is a version of the tree: its keys and signed root.
+type version struct {
+	d    gosmt.D
+	root *gosmt.SignedRoot
+}
+
+// server wraps a SMT, its keys and the recent versions.
+type server struct {
+	sync.RWMutex
+	mux      *http.ServeMux
+	smt      *gosmt.SMT
+	priv     ed25519.PrivateKey
+	wal      *gosmt.WAL
+	history  int
+	versions []version // the last history versions, the current one last
+}
+
+func newServer(priv ed25519.PrivateKey, history int) *server {
+	srv := new(server)
+	srv.smt = gosmt.NewSMT([]byte{0x42},
+		gosmt.CacheBranch(make(map[string][]byte)), hash)
+	srv.priv = priv
+	srv.history = max(history, 1)
+	srv.versions = []version{{
+		d:    gosmt.D{},
+		root: gosmt.SignRoot(priv, 0, srv.smt.RootHash(gosmt.D{}, srv.smt.N, srv.smt.Base)),
+	}}
+
+	srv.mux = http.NewServeMux()
+	srv.mux.HandleFunc("POST /update", srv.handleUpdate)
+	srv.mux.HandleFunc("GET /root", srv.handleRoot)
+	srv.mux.HandleFunc("GET /proof", srv.handleProof)
+	return srv
+}
+
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// openWAL opens the write-ahead log at path and replays it.
+func (srv *server) openWAL(path string) error {
+	wal, err := gosmt.OpenWAL(path)
+	if err != nil {
+		return err
+	}
+	if err = wal.Recover(func(b *gosmt.Batch) []byte {
+		root, _ := srv.apply(b.Keys, b.Value)
+		return root
+	}); err != nil {
+		wal.Close()
+		return err
+	}
+	srv.wal = wal
+	return nil
+}
+
+// errInvalidKey is returned by update for a key of the wrong length, the only
+// error caused by the request rather than the server.
+var errInvalidKey = errors.New("invalid key length")
+
+// update logs and applies a batch, returning the new signed root. The batch
+// is only applied to the tree once its root is committed to the log, and
+// discarded from both on failure.
+func (srv *server) update(fresh [][]byte, value []byte) (*gosmt.SignedRoot, error) {
+	srv.Lock()
+	defer srv.Unlock()
+	for _, k := range fresh {
+		if uint64(len(k)) != srv.smt.N/8 {
+			return nil, errInvalidKey
+		}
+	}
+	keys := gosmt.NewKeys(fresh...)
+
+	if srv.wal != nil {
+		if err := srv.wal.Append(keys, value, nil); err != nil {
+			return nil, err
+		}
+	}
+	tx, d, root, err := srv.prepare(keys, value)
+	if err == nil && srv.wal != nil {
+		if err = srv.wal.Commit(root); err != nil {
+			tx.Rollback()
+		}
+	}
+	if err != nil {
+		if srv.wal != nil {
+			// if this fails too, the batch stays pending and all further
+			// updates fail until restarting and recovering from the log
+			err = errors.Join(err, srv.wal.Abort())
+		}
+		return nil, err
+	}
+	srv.commit(tx, d, root)
+	return srv.current().root, nil
+}
+
+// apply applies a batch, e.g., when replaying the log, returning the root.
+func (srv *server) apply(keys gosmt.Key, value []byte) ([]byte, error) {
+	tx, d, root, err := srv.prepare(keys, value)
+	if err != nil {
+		return nil, err
+	}
+	srv.commit(tx, d, root)
+	return root, nil
+}
+
+// prepare updates the keys of the current version in a transaction,
+// returning the transaction, the new keys and root.
+func (srv *server) prepare(keys gosmt.Key, value []byte) (*gosmt.Tx,
+	gosmt.D, []byte, error) {
+	d := srv.current().d
+	if bytes.Equal(value, gosmt.Empty) {
+		d = d.Remove(keys.All()...)
+	} else {
+		d = d.Insert(keys.All()...)
+	}
+
+	tx, err := srv.smt.Begin()
+	if err != nil {
+		return nil, gosmt.D{}, nil, err
+	}
+	return tx, d, tx.Update(d, keys, srv.smt.N, srv.smt.Base, value), nil
+}
+
+// commit commits a prepared transaction and adds the new version.
+func (srv *server) commit(tx *gosmt.Tx, d gosmt.D, root []byte) {
+	if err := tx.Commit(); err != nil {
+		// all updates hold the lock, so transactions never conflict
+		panic(err)
+	}
+	srv.versions = append(srv.versions, version{
+		d:    d,
+		root: gosmt.SignRoot(srv.priv, srv.current().root.Version+1, root),
+	})
+	if len(srv.versions) > srv.history {
+		srv.versions = srv.versions[len(srv.versions)-srv.history:]
+	}
+}
+
+func (srv *server) current() version {
+	return srv.versions[len(srv.versions)-1]
+}
+
+// version returns the version requested in the query of r, the current one
+// if none.
+func (srv *server) version(r *http.Request) (version, error) {
+	current := srv.current()
+	q := r.URL.Query().Get("version")
+	if q == "" {
+		return current, nil
+	}
+	n, err := strconv.ParseUint(q, 10, 64)
+	if err != nil {
+		return version{}, errors.New("invalid version")
+	}
+	first := srv.versions[0].root.Version
+	if n < first || n > current.root.Version {
+		return version{}, errors.New("unknown version")
+	}
+	return srv.versions[n-first], nil
+}
+
+func (srv *server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	var req updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	value := gosmt.Set
+	if req.Remove {
+		value = gosmt.Empty
+	}
+	root, err := srv.update(req.Keys, value)
+	switch {
+	case errors.Is(err, errInvalidKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil: // e.g., failing to write or sync the log
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, root)
+}
+
+func (srv *server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	srv.RLock()
+	defer srv.RUnlock()
+	v, err := srv.version(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, v.root)
+}
+
+func (srv *server) handleProof(w http.ResponseWriter, r *http.Request) {
+	key, err := hex.DecodeString(r.URL.Query().Get("key"))
+	if err != nil || uint64(len(key)) != srv.smt.N/8 {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	srv.RLock()
+	defer srv.RUnlock()
+	v, err := srv.version(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s := srv.smt
+	if v.root.Version != srv.current().root.Version {
+		// The cache is only for the current version, so a proof for an older
+		// version hashes all its n keys, O(n*N) hashes, while holding the
+		// read lock. Caching every version would instead multiply memory by
+		// -history, so large trees should keep -history small.
+		s = gosmt.NewSMT([]byte{0x42}, gosmt.CacheNothing(0), hash)
+	}
+	p := s.Prove(v.d, key)
+	p.Root = v.root
+	writeJSON(w, p)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pylls/gosmt"
+)
+
+func TestServer(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(priv, 4)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	smt := gosmt.NewSMT([]byte{0x42}, gosmt.CacheNothing(0), hash)
+
+	keys := [][]byte{hash([]byte("a")), hash([]byte("b")), hash([]byte("c"))}
+	root := update(t, ts.URL, keys, false)
+	if root.Version != 1 || !root.Verify(pub) {
+		t.Fatalf("invalid root after update: %+v", root)
+	}
+	if want := smt.RootHash(gosmt.NewKeys(keys...), smt.N, smt.Base); !bytes.Equal(root.Root, want) {
+		t.Fatal("root differs from a SMT with the same keys")
+	}
+	update(t, ts.URL, keys[:1], true)
+
+	var current gosmt.SignedRoot
+	get(t, ts.URL+"/root", &current)
+	if current.Version != 2 || !current.Verify(pub) {
+		t.Fatalf("invalid current root: %+v", current)
+	}
+
+	for _, tc := range []struct {
+		key     []byte
+		version string
+		member  bool
+	}{
+		{keys[0], "", false},
+		{keys[1], "", true},
+		{keys[0], "1", true},
+		{hash([]byte("d")), "1", false},
+	} {
+		url := ts.URL + "/proof?key=" + hex.EncodeToString(tc.key)
+		if tc.version != "" {
+			url += "&version=" + tc.version
+		}
+		var p gosmt.Proof
+		get(t, url, &p)
+		if !smt.VerifyProof(&p, pub) || p.Member() != tc.member {
+			t.Fatalf("invalid proof for %x at version %q", tc.key, tc.version)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/root?version=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("got status %d for unknown version", resp.StatusCode)
+	}
+}
+
+func TestServerWAL(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "wal")
+
+	srv := newServer(priv, 4)
+	if err = srv.openWAL(path); err != nil {
+		t.Fatal(err)
+	}
+	root, err := srv.update([][]byte{hash([]byte("a")), hash([]byte("b"))}, gosmt.Set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := updateStatus(srv, [][]byte{[]byte("short")}); code != http.StatusBadRequest {
+		t.Fatalf("got status %d for an invalid key", code)
+	}
+	srv.wal.Close()
+
+	// an update that fails to be logged is not applied, and fails the server
+	if _, err = srv.update([][]byte{hash([]byte("c"))}, gosmt.Set); err == nil {
+		t.Fatal("updated without a log")
+	}
+	if code := updateStatus(srv, [][]byte{hash([]byte("c"))}); code != http.StatusInternalServerError {
+		t.Fatalf("got status %d for failing to log", code)
+	}
+	if got := srv.current().root; got.Version != root.Version ||
+		!bytes.Equal(got.Root, srv.smt.RootHash(srv.current().d, srv.smt.N, srv.smt.Base)) {
+		t.Fatal("failed update changed the tree")
+	}
+
+	srv = newServer(priv, 4)
+	if err = srv.openWAL(path); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.wal.Close()
+	if got := srv.current().root; got.Version != root.Version ||
+		!bytes.Equal(got.Root, root.Root) {
+		t.Fatal("recovered another root")
+	}
+}
+
+func update(t *testing.T, url string, keys [][]byte, remove bool) *gosmt.SignedRoot {
+	body, err := json.Marshal(updateRequest{Keys: keys, Remove: remove})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url+"/update", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update failed with status %d", resp.StatusCode)
+	}
+	root := new(gosmt.SignedRoot)
+	if err = json.NewDecoder(resp.Body).Decode(root); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// updateStatus posts an update of keys to srv, returning the status code.
+func updateStatus(srv *server, keys [][]byte) int {
+	body, _ := json.Marshal(updateRequest{Keys: keys})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/update", bytes.NewReader(body)))
+	return w.Code
+}
+
+func get(t *testing.T, url string, v interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s failed with status %d", url, resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
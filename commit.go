@@ -0,0 +1,129 @@
+package gosmt
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+)
+
+// NonceSize is the size, in bytes, of the random nonce of a Commitment.
+const NonceSize = 32
+
+// Commitment is a hiding commitment to the value of a leaf. The leaf only
+// commits to H(nonce || value), so sibling hashes in audit paths for other
+// keys reveal nothing about the value even if the value space is small.
+type Commitment struct {
+	Nonce []byte
+	Value []byte
+}
+
+// NewCommitment creates a new commitment to value with a fresh random nonce.
+func NewCommitment(value []byte) (*Commitment, error) {
+	c := new(Commitment)
+	c.Nonce = make([]byte, NonceSize)
+	if _, err := rand.Read(c.Nonce); err != nil {
+		return nil, err
+	}
+	c.Value = value
+	return c, nil
+}
+
+// Digest returns H(nonce || value), the value that the leaf hashes.
+func (c *Commitment) Digest(hash func(data ...[]byte) []byte) []byte {
+	return hash(c.Nonce, c.Value)
+}
+
+// MarshalBinary encodes the commitment as nonce || value, e.g., to log it in
+// the Values of a WAL Batch.
+func (c *Commitment) MarshalBinary() ([]byte, error) {
+	if len(c.Nonce) != NonceSize {
+		return nil, errors.New("invalid nonce size")
+	}
+	return append(append([]byte{}, c.Nonce...), c.Value...), nil
+}
+
+// UnmarshalBinary decodes a commitment encoded by MarshalBinary.
+func (c *Commitment) UnmarshalBinary(data []byte) error {
+	if len(data) < NonceSize {
+		return errors.New("commitment too short")
+	}
+	c.Nonce = append([]byte{}, data[:NonceSize]...)
+	c.Value = append([]byte{}, data[NonceSize:]...)
+	return nil
+}
+
+// Commitments stores the commitment, i.e., the value and its nonce, of each
+// leaf. It implements Values, so setting it as the Values of a SMT makes each
+// leaf commit to H(nonce || value).
+type Commitments struct {
+	sync.RWMutex
+	hash   func(data ...[]byte) []byte
+	leaves map[string]*Commitment
+	digest map[string][]byte
+}
+
+// NewCommitments creates a new empty store of commitments that uses the
+// provided hash function (should be the same as the SMT).
+func NewCommitments(hash func(data ...[]byte) []byte) *Commitments {
+	c := new(Commitments)
+	c.hash = hash
+	c.leaves = make(map[string]*Commitment)
+	c.digest = make(map[string][]byte)
+	return c
+}
+
+// Commit stores a new commitment to value for key, replacing any existing
+// commitment. The key still has to be added to the SMT with an update.
+func (c *Commitments) Commit(key, value []byte) (*Commitment, error) {
+	com, err := NewCommitment(value)
+	if err != nil {
+		return nil, err
+	}
+	c.Restore(key, com)
+	return com, nil
+}
+
+// Restore stores an existing commitment for key, e.g., one replayed from a
+// WAL, replacing any existing commitment.
+func (c *Commitments) Restore(key []byte, com *Commitment) {
+	c.Lock()
+	defer c.Unlock()
+	c.leaves[string(key)] = com
+	c.digest[string(key)] = com.Digest(c.hash)
+}
+
+// Remove removes the commitment for key.
+func (c *Commitments) Remove(key []byte) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.leaves, string(key))
+	delete(c.digest, string(key))
+}
+
+// Get returns the commitment for key, to be revealed with an audit path in a
+// membership proof, or nil if there is none.
+func (c *Commitments) Get(key []byte) *Commitment {
+	c.RLock()
+	defer c.RUnlock()
+	return c.leaves[string(key)]
+}
+
+// Value returns the digest of the commitment for key, or Set if there is none.
+func (c *Commitments) Value(key []byte) []byte {
+	c.RLock()
+	defer c.RUnlock()
+	if d, exists := c.digest[string(key)]; exists {
+		return d
+	}
+	return Set
+}
+
+// VerifyCommitment verifies a membership proof for key consisting of an audit
+// path and the opened commitment to its value. The nonce must be NonceSize
+// bytes, otherwise bytes could be moved between the nonce and the value to
+// open the commitment to another value.
+func (s *SMT) VerifyCommitment(ap [][]byte, key []byte, c *Commitment,
+	root []byte) bool {
+	return c != nil && len(c.Nonce) == NonceSize &&
+		s.VerifyAuditPath(ap, key, c.Digest(s.hash), root)
+}
@@ -0,0 +1,66 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCommitments(t *testing.T) {
+	com := NewCommitments(hash)
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	s.Values = com
+
+	data := getFreshData(16)
+	for i := 0; i < data.Len(); i++ {
+		if _, err := com.Commit(data.At(i), []byte{byte(i % 2)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := s.Update(data, data, s.N, s.Base, Set)
+	if !bytes.Equal(root, s.RootHash(data, s.N, s.Base)) {
+		t.Fatal("roots mismatch")
+	}
+
+	for i := 0; i < data.Len(); i++ {
+		ap := s.AuditPath(data, s.N, s.Base, data.At(i))
+		c := com.Get(data.At(i))
+		if !s.VerifyCommitment(ap, data.At(i), c, root) {
+			t.Fatal("failed to verify valid commitment")
+		}
+		if s.VerifyCommitment(ap, data.At(i),
+			&Commitment{Nonce: c.Nonce, Value: []byte{byte(i%2 + 1)}}, root) {
+			t.Fatal("verified commitment to wrong value")
+		}
+		if s.VerifyAuditPath(ap, data.At(i), Set, root) {
+			t.Fatal("verified membership without opening the commitment")
+		}
+		// moving the last byte of the nonce into the value has the same digest
+		forged := &Commitment{Nonce: c.Nonce[:NonceSize-1],
+			Value: append([]byte{c.Nonce[NonceSize-1]}, c.Value...)}
+		if !bytes.Equal(forged.Digest(hash), c.Digest(hash)) ||
+			s.VerifyCommitment(ap, data.At(i), forged, root) {
+			t.Fatal("verified commitment with a short nonce")
+		}
+	}
+
+	// same values, fresh nonces: the root must change
+	for i := 0; i < data.Len(); i++ {
+		if _, err := com.Commit(data.At(i), []byte{byte(i % 2)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bytes.Equal(root, s.Update(data, data, s.N, s.Base, Set)) {
+		t.Fatal("root does not depend on nonces")
+	}
+
+	// removing a key and its commitment
+	com.Remove(data.At(0))
+	if com.Get(data.At(0)) != nil || !bytes.Equal(com.Value(data.At(0)), Set) {
+		t.Fatal("failed to remove commitment")
+	}
+	root = s.Update(data.Slice(1, data.Len()), data.Slice(0, 1), s.N, s.Base, Empty)
+	ap := s.AuditPath(data.Slice(1, data.Len()), s.N, s.Base, data.At(0))
+	if !s.VerifyAuditPath(ap, data.At(0), Empty, root) {
+		t.Fatal("failed to verify valid non-membership proof")
+	}
+}
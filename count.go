@@ -0,0 +1,24 @@
+package gosmt
+
+// counts is a Balances where every set leaf has a balance of one.
+type counts struct{}
+
+// Balance returns one, the count of a single leaf.
+func (counts) Balance(key []byte) int64 { return 1 }
+
+// NewCountSMT creates a new SumSMT where every node is annotated with the
+// number of keys below it, e.g., the sum of the root is the number of keys
+// in the tree. Like for NewSMT, it requires a default empty leaf constant c,
+// a caching strategy cache and a hash function.
+func NewCountSMT(c []byte, cache Cache, hash func(data ...[]byte) []byte) *SumSMT {
+	return NewSumSMT(c, cache, counts{}, hash)
+}
+
+// VerifyCount verifies that there are exactly count keys below the subtree
+// with the provided height and base, given an audit path for its root node.
+// For height N, the audit path is empty and node is the root.
+func (s *SumSMT) VerifyCount(ap [][]byte, height uint64, base, node []byte,
+	count int64, root []byte) bool {
+	return s.Sum(node) == count &&
+		s.VerifySubtreeAuditPath(ap, height, base, node, root)
+}
@@ -0,0 +1,67 @@
+package gosmt
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Difference lists the keys that differ from one SMT to another.
+type Difference struct {
+	Added   Key // keys only in the other SMT
+	Removed Key // keys only in the first SMT
+	Changed Key // keys in both SMTs, but with different values
+}
+
+// Diff returns the keys that differ from a, with the keys da, to b, with the
+// keys db, e.g., two replicas or two versions. It walks both trees from the
+// root and only descends where the nodes differ, so with cached nodes the
+// cost depends on the number of differences rather than the size of the
+// trees. Both SMTs must use the same tree-wide constant and hash.
+func Diff(a *SMT, da LeafStore, b *SMT, db LeafStore) (*Difference, error) {
+	if a.N != b.N || !bytes.Equal(a.c, b.c) ||
+		!bytes.Equal(a.defaultHash(a.N), b.defaultHash(b.N)) {
+		return nil, errors.New("trees with different constant or hash")
+	}
+	d := new(differences)
+	diff(a, a.nodes(), leafStore(da), b, b.nodes(), leafStore(db), a.N, a.Base, d)
+	return d.difference(), nil
+}
+
+// differences collects the keys of a Difference.
+type differences struct {
+	added, removed, changed [][]byte
+}
+
+func (d *differences) difference() *Difference {
+	return &Difference{Added: NewKeys(d.added...),
+		Removed: NewKeys(d.removed...), Changed: NewKeys(d.changed...)}
+}
+
+// diff collects the differences below the node at height and base, using the
+// nodes na of a and nb of b.
+func diff(a *SMT, na nodes, da LeafStore, b *SMT, nb nodes, db LeafStore,
+	height uint64, base []byte, d *differences) {
+	ha, hb := a.rootHash(na, da, height, base), b.rootHash(nb, db, height, base)
+	switch {
+	case bytes.Equal(ha, hb):
+		return
+	case bytes.Equal(ha, na.defaults[height]):
+		db.Iterate(func(key []byte) bool {
+			d.added = append(d.added, key)
+			return true
+		})
+	case bytes.Equal(hb, nb.defaults[height]):
+		da.Iterate(func(key []byte) bool {
+			d.removed = append(d.removed, key)
+			return true
+		})
+	case height == 0:
+		d.changed = append(d.changed, base)
+	default:
+		split := bitSplit(base, a.N-height)
+		la, ra := da.SplitAt(split)
+		lb, rb := db.SplitAt(split)
+		diff(a, na, la, b, nb, lb, height-1, base, d)
+		diff(a, na, ra, b, nb, rb, height-1, split, d)
+	}
+}
@@ -0,0 +1,59 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	values := make(mapValues)
+	a := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	b := NewSMT([]byte{0x42}, CacheBranchPlus(make(map[string][]byte)), hash)
+	b.Values = values
+
+	da := getFreshData(128)
+	a.BulkLoad(da)
+
+	added := getFreshData(5)
+	removed := NewKeys(da.At(3), da.At(50), da.At(100))
+	changed := NewKeys(da.At(7), da.At(64))
+	for _, k := range changed.All() {
+		values[string(k)] = []byte("changed")
+	}
+	db := da.Insert(added.All()...).Remove(removed.All()...)
+	b.BulkLoad(db)
+
+	d, err := Diff(a, da, b, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ got, expected Key }{
+		{d.Added, added}, {d.Removed, removed}, {d.Changed, changed},
+	} {
+		if c.got.Len() != c.expected.Len() {
+			t.Fatalf("expected %d keys, got %d", c.expected.Len(), c.got.Len())
+		}
+		for i := 0; i < c.got.Len(); i++ {
+			if !bytes.Equal(c.got.At(i), c.expected.At(i)) {
+				t.Fatal("wrong key in difference")
+			}
+		}
+	}
+
+	if d, _ = Diff(a, da, a, da); d.Added.Len()+d.Removed.Len()+d.Changed.Len() != 0 {
+		t.Fatal("difference between identical trees")
+	}
+	if _, err = Diff(a, da, NewSMT([]byte{0x43}, CacheNothing(0), hash), db); err == nil {
+		t.Fatal("diffed trees with different constants")
+	}
+}
+
+// mapValues is a Values backed by a map, Set for missing keys.
+type mapValues map[string][]byte
+
+func (m mapValues) Value(key []byte) []byte {
+	if v, exists := m[string(key)]; exists {
+		return v
+	}
+	return Set
+}
@@ -0,0 +1,121 @@
+package gosmt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// FileStore is a read-only LeafStore of a file of sorted keys, stored as
+// concatenated keys of the same size (see WriteKeys). Keys are read from the
+// file as needed, so only O(log n) reads are needed to split the store.
+// Since LeafStore has no errors, a read error panics with a *FileStoreError,
+// rather than making the store look empty and the roots computed from it
+// wrong.
+type FileStore struct {
+	file *keyFile
+	i, j int // the view of the keys with index [i, j)
+}
+
+type keyFile struct {
+	f    *os.File
+	size int
+}
+
+// FileStoreError is the value of the panic on a read error of a FileStore.
+type FileStoreError struct {
+	Err error
+}
+
+func (e *FileStoreError) Error() string {
+	return "reading file store: " + e.Err.Error()
+}
+
+func (e *FileStoreError) Unwrap() error {
+	return e.Err
+}
+
+// OpenFileStore opens the file at path with keys of the provided size.
+func OpenFileStore(path string, size int) (*FileStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if size <= 0 || info.Size()%int64(size) != 0 {
+		f.Close()
+		return nil, errors.New("file size is not a multiple of the key size")
+	}
+	return &FileStore{
+		file: &keyFile{f: f, size: size},
+		j:    int(info.Size() / int64(size)),
+	}, nil
+}
+
+// Close closes the underlying file, for all views of the store.
+func (fs *FileStore) Close() error {
+	return fs.file.f.Close()
+}
+
+// Len returns the number of keys in the store.
+func (fs *FileStore) Len() int {
+	return fs.j - fs.i
+}
+
+// Count returns the number of keys k with lo <= k < hi, where a nil hi is
+// the end of the key space.
+func (fs *FileStore) Count(lo, hi []byte) int {
+	if hi == nil {
+		return fs.j - fs.search(lo)
+	}
+	if bytes.Compare(lo, hi) >= 0 {
+		return 0
+	}
+	return fs.search(hi) - fs.search(lo)
+}
+
+// SplitAt splits the store into views of the keys smaller than s and the rest.
+func (fs *FileStore) SplitAt(s []byte) (l, r LeafStore) {
+	i := fs.search(s)
+	return &FileStore{file: fs.file, i: fs.i, j: i},
+		&FileStore{file: fs.file, i: i, j: fs.j}
+}
+
+// Iterate calls f with each key in ascending order until f returns false.
+func (fs *FileStore) Iterate(f func(key []byte) bool) {
+	size := int64(fs.file.size)
+	r := bufio.NewReader(io.NewSectionReader(fs.file.f, int64(fs.i)*size,
+		int64(fs.Len())*size))
+	for i := fs.i; i < fs.j; i++ {
+		key := make([]byte, size)
+		if _, err := io.ReadFull(r, key); err != nil {
+			panic(&FileStoreError{err})
+		}
+		if !f(key) {
+			return
+		}
+	}
+}
+
+// search returns the smallest index i in the view where key i >= s.
+func (fs *FileStore) search(s []byte) int {
+	return fs.i + sort.Search(fs.Len(), func(i int) bool {
+		return bytes.Compare(fs.file.key(fs.i+i), s) >= 0
+	})
+}
+
+// key returns the key with index i.
+func (kf *keyFile) key(i int) []byte {
+	key := make([]byte, kf.size)
+	if _, err := kf.f.ReadAt(key, int64(i)*int64(kf.size)); err != nil {
+		panic(&FileStoreError{err})
+	}
+	return key
+}
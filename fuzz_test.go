@@ -0,0 +1,157 @@
+package gosmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// fuzzUniverse is the number of keys a fuzzed sequence picks from. Keys come
+// in groups of four that only differ in their last bits, so that the
+// sequences hit deep shared prefixes as well as sparse subtrees.
+const fuzzUniverse = 64
+
+// fuzzMaxSteps bounds the number of operations of a fuzzed sequence.
+const fuzzMaxSteps = 32
+
+// fuzzHash is hash truncated to 32 bits, so the trees of the fuzz target are
+// cheap to compute without a cache and keys often share long prefixes.
+func fuzzHash(data ...[]byte) []byte {
+	return hash(data...)[:4]
+}
+
+func fuzzKey(i byte) []byte {
+	key := fuzzHash(binary.BigEndian.AppendUint64(nil, uint64(i%fuzzUniverse/4)))
+	key[len(key)-1] = i % 4
+	return key
+}
+
+// FuzzCaches applies sequences of inserts and deletes, read as pairs of an
+// operation and a key index, to a SMT with each caching strategy. After each
+// step the roots are compared with a naive uncached reference, and proofs
+// for a member and a non-member are generated and verified.
+func FuzzCaches(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 2, 0, 3, 1, 2, 0, 9, 2, 0})
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 3, 2, 1, 2, 1, 0, 1, 1, 0})
+	f.Add([]byte{0, 5, 0, 17, 0, 33, 0, 63, 0, 4, 2, 2, 1, 63, 0, 6, 2, 0})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		if len(ops) > 2*fuzzMaxSteps {
+			ops = ops[:2*fuzzMaxSteps]
+		}
+		c := []byte{0x42}
+		smts := []*SMT{
+			NewSMT(c, CacheNothing(0), fuzzHash),
+			NewSMT(c, NewCacheBranchMinus(0.5), fuzzHash),
+			NewSMT(c, CacheBranch(make(map[string][]byte)), fuzzHash),
+			NewSMT(c, CacheBranchPlus(make(map[string][]byte)), fuzzHash),
+		}
+		ref := newNaiveSMT(c, fuzzHash)
+		members := make(map[string]bool)
+
+		for i := 0; i+1 < len(ops); i += 2 {
+			op, idx := ops[i]%3, ops[i+1]
+			key, value := fuzzKey(idx), Set
+			switch op {
+			case 0: // insert
+				members[string(key)] = true
+			case 1: // delete, the key may not be a member
+				delete(members, string(key))
+				value = Empty
+			case 2: // delete a member, anywhere in the tree
+				d := keysOf(members)
+				if d.Len() == 0 {
+					continue
+				}
+				key, value = d.At(int(idx)%d.Len()), Empty
+				delete(members, string(key))
+			}
+			d := keysOf(members)
+			expected := ref.root(d.All())
+
+			nonMember := fuzzKey(idx + 1)
+			for members[string(nonMember)] {
+				nonMember = fuzzHash(nonMember)
+			}
+			for j, s := range smts {
+				root := s.Update(d, NewKeys(key), s.N, s.Base, value)
+				if !bytes.Equal(root, expected) {
+					t.Fatalf("step %d: cache %d: root differs from reference", i/2, j)
+				}
+				if !bytes.Equal(s.RootHash(d, s.N, s.Base), expected) {
+					t.Fatalf("step %d: cache %d: RootHash differs from reference", i/2, j)
+				}
+
+				ap := s.AuditPath(d, s.N, s.Base, nonMember)
+				if !s.VerifyAuditPath(ap, nonMember, Empty, root) ||
+					s.VerifyAuditPath(ap, nonMember, Set, root) {
+					t.Fatalf("step %d: cache %d: bad non-membership proof", i/2, j)
+				}
+				if d.Len() > 0 {
+					member := d.At(int(idx) % d.Len())
+					ap = s.AuditPath(d, s.N, s.Base, member)
+					if !s.VerifyAuditPath(ap, member, Set, root) ||
+						s.VerifyAuditPath(ap, member, Empty, root) {
+						t.Fatalf("step %d: cache %d: bad membership proof", i/2, j)
+					}
+				}
+			}
+		}
+	})
+}
+
+func keysOf(members map[string]bool) D {
+	keys := make([][]byte, 0, len(members))
+	for k := range members {
+		keys = append(keys, []byte(k))
+	}
+	return NewKeys(keys...)
+}
+
+// naiveSMT computes roots directly from the definition of the tree, with no
+// caching, D or shared code with SMT.
+type naiveSMT struct {
+	c        []byte
+	hash     func(data ...[]byte) []byte
+	n        int
+	defaults [][]byte
+}
+
+func newNaiveSMT(c []byte, hash func(data ...[]byte) []byte) *naiveSMT {
+	r := &naiveSMT{c: c, hash: hash, n: len(hash(nil)) * 8}
+	r.defaults = [][]byte{hash(c)}
+	for i := 1; i <= r.n; i++ {
+		r.defaults = append(r.defaults, hash(r.defaults[i-1], r.defaults[i-1]))
+	}
+	return r
+}
+
+func (r *naiveSMT) root(keys [][]byte) []byte {
+	return r.node(keys, r.n, make([]byte, r.n/8))
+}
+
+func (r *naiveSMT) node(keys [][]byte, height int, base []byte) []byte {
+	if len(keys) == 0 {
+		return r.defaults[height]
+	}
+	if height == 0 {
+		return r.hash(r.c, base)
+	}
+	bit := r.n - height // from the most significant bit
+	right := append([]byte{}, base...)
+	right[bit/8] |= 0x80 >> uint(bit%8)
+
+	var lk, rk [][]byte
+	for _, k := range keys {
+		if k[bit/8]&(0x80>>uint(bit%8)) == 0 {
+			lk = append(lk, k)
+		} else {
+			rk = append(rk, k)
+		}
+	}
+	left, rightHash := r.node(lk, height-1, base), r.node(rk, height-1, right)
+	if bytes.Equal(left, rightHash) {
+		return r.hash(left, rightHash)
+	}
+	return r.hash(left, rightHash, base,
+		binary.BigEndian.AppendUint64(nil, uint64(height)))
+}
@@ -3,7 +3,6 @@ package gosmt
 import (
 	"bytes"
 	"encoding/binary"
-	"sort"
 )
 
 // constants (has to be var in Go, slices evaluated at runtime)
@@ -14,46 +13,19 @@ var (
 	Set = []byte{0x1}
 )
 
-type Trie interface {
-	sort.Interface
-	Split(s []byte) (l, r Trie)
+// Trie is a sorted set of keys, splitable on split index. Keys is a Trie, and
+// Update takes the keys to update as a Trie of Key.
+type Trie[T any] interface {
+	Len() int
+	Split(s []byte) (l, r T)
 }
 
 // D is our data structure to authenticate,
-// D[index] = key of type: []byte
-type D [][]byte
-
-// sort.Interface method for sorting
-func (d D) Len() int           { return len(d) }
-func (d D) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
-func (d D) Less(i, j int) bool { return bytes.Compare(d[i], d[j]) == -1 }
-
-// Split splits d based on Split index s.
-func (d D) Split(s []byte) (l, r D) {
-	// make sure d is stable-sorted first
-	sort.Stable(d)
-	// the smallest index i where d[i] >= s
-	i := sort.Search(d.Len(), func(i int) bool {
-		return bytes.Compare(d[i], s) >= 0
-	})
-	return d[:i], d[i:]
-}
-
-// Key also implements Trie interface, splitable on split index.
-type Key [][]byte
-
-func (k Key) Len() int           { return len(k) }
-func (k Key) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
-func (k Key) Less(i, j int) bool { return bytes.Compare(k[i], k[j]) == -1 }
-func (k Key) Split(s []byte) (l, r Key) {
-	// make sure k is stable-sorted first
-	sort.Stable(k)
-	// the smallest index i where d[i] >= s
-	i := sort.Search(k.Len(), func(i int) bool {
-		return bytes.Compare(k[i], s) >= 0
-	})
-	return k[:i], k[i:]
-}
+// D.At(index) = key of type: []byte
+type D = Keys[[]byte]
+
+// Key are the keys to update, also a Trie splitable on split index.
+type Key = Keys[[]byte]
 
 // SMT is a sparse Merkle tree.
 type SMT struct {
@@ -63,12 +35,22 @@ type SMT struct {
 	hash          func(data ...[]byte) []byte
 	N             uint64   // output length, in bits, of hash
 	defaultHashes [][]byte // [height][]byte, one default byte string per height (range:[0, N]), leaf node has height of 0, root node has height of N.
+	Values        Values   // optional per-leaf values, if nil every key in D is a Set leaf
+	gen           *uint64  // generation of the cache, incremented on writes, see Tx
+}
+
+// Values looks up the value that the leaf of a key commits to.
+type Values interface {
+	// Value returns the value of the leaf with the provided key, or Set if
+	// the key has no particular value.
+	Value(key []byte) []byte
 }
 
 // NewSMT creates a new SMT. SMT instantiation requires a default empty leaf constant c, a caching strategy cache (e.g. CacheBranch, CacheBranchPlus), and a particular hash function (e.g. SHA256)
 func NewSMT(c []byte, cache Cache, hash func(data ...[]byte) []byte) *SMT {
 	s := new(SMT)
 	s.cache = cache
+	s.gen = new(uint64)
 	s.hash = hash
 	s.c = c
 	s.N = uint64(len(hash([]byte("smt"))) * 8) // hash any string to get output length
@@ -82,13 +64,56 @@ func NewSMT(c []byte, cache Cache, hash func(data ...[]byte) []byte) *SMT {
 	return s
 }
 
-// Update updates keys to the value. Note: d and keys should be sorted as param.
-func (s *SMT) Update(d D, keys Key, height uint64, base, value []byte) []byte {
-	if height == 0 {
-		return s.leafHash(value, base)
+// nodes computes the nodes of a tree. The SMT and trees that annotate its
+// nodes, like the SumSMT, share the walks of Update, RootHash, AuditPath and
+// verification, only computing their nodes differently. A nil node is
+// invalid and makes every node above it nil.
+type nodes struct {
+	// leaf returns the leaf at base updated to value, where Set is the value
+	// of the key.
+	leaf     func(base, value []byte) []byte
+	interior func(left, right []byte, height uint64, base []byte) []byte
+	defaults [][]byte // [height], the node of an empty subtree
+}
+
+// nodes returns the nodes of the SMT.
+func (s *SMT) nodes() nodes {
+	return nodes{
+		leaf: func(base, value []byte) []byte {
+			if bytes.Equal(value, Set) {
+				return s.leafHash(s.leafValue(base), base)
+			}
+			return s.leafHash(value, base)
+		},
+		interior: s.interiorHash,
+		defaults: s.defaultHashes,
+	}
+}
+
+// Update updates keys to the value. Note: d is the keys after the update, in
+// any LeafStore. Update only splits d along the paths of keys, and computes
+// sibling hashes from it only if they are not cached, so d is never copied.
+// If s.Values is set, updating to Set uses the value of each key from s.Values.
+// The value is Set or Empty, for any other value Update returns nil without
+// updating, since leaves only commit to other values through s.Values.
+func (s *SMT) Update(d LeafStore, keys Trie[Key], height uint64, base, value []byte) []byte {
+	if !validValue(value) {
+		return nil
+	}
+	*s.gen++
+	return s.update(s.nodes(), leafStore(d), keys, height, base, value)
+}
+
+func (s *SMT) update(n nodes, d LeafStore, keys Trie[Key], height uint64,
+	base, value []byte) []byte {
+	switch {
+	case keys.Len() == 0:
+		return s.rootHash(n, d, height, base)
+	case height == 0:
+		return n.leaf(base, value)
 	}
 	split := bitSplit(base, s.N-height)
-	ld, rd := d.Split(split)
+	ld, rd := d.SplitAt(split)
 	lkeys, rkeys := keys.Split(split)
 
 	// When there's a key falling within the range of left/right subtree, meaning
@@ -98,72 +123,102 @@ func (s *SMT) Update(d D, keys Key, height uint64, base, value []byte) []byte {
 	// its root hash and update upwards recursively.
 	switch {
 	case lkeys.Len() == 0 && rkeys.Len() > 0:
-		return s.cache.HashCache(s.RootHash(ld, height-1, base),
-			s.Update(rd, keys, height-1, split, value),
-			height, base, split, s.interiorHash, s.defaultHashes)
+		return s.cache.HashCache(s.rootHash(n, ld, height-1, base),
+			s.update(n, rd, keys, height-1, split, value),
+			height, base, split, n.interior, n.defaults)
 	case lkeys.Len() > 0 && rkeys.Len() == 0:
-		return s.cache.HashCache(s.Update(ld, keys, height-1, base, value),
-			s.RootHash(rd, height-1, split),
-			height, base, split, s.interiorHash, s.defaultHashes)
+		return s.cache.HashCache(s.update(n, ld, keys, height-1, base, value),
+			s.rootHash(n, rd, height-1, split),
+			height, base, split, n.interior, n.defaults)
 	default:
-		return s.cache.HashCache(s.Update(ld, lkeys, height-1, base, value),
-			s.Update(rd, rkeys, height-1, split, value),
-			height, base, split, s.interiorHash, s.defaultHashes)
+		return s.cache.HashCache(s.update(n, ld, lkeys, height-1, base, value),
+			s.update(n, rd, rkeys, height-1, split, value),
+			height, base, split, n.interior, n.defaults)
 	}
 }
 
 // AuditPath generates an audit path.
-func (s *SMT) AuditPath(d D, height uint64, base, key []byte) [][]byte {
-	if height == 0 {
+func (s *SMT) AuditPath(d LeafStore, height uint64, base, key []byte) [][]byte {
+	return s.auditPath(s.nodes(), leafStore(d), height, base, key, 0)
+}
+
+// SubtreeAuditPath generates an audit path for the root of the subtree with
+// the provided height and base. Appending it to an audit path generated
+// within the subtree gives the audit path in the whole SMT.
+func (s *SMT) SubtreeAuditPath(d LeafStore, height uint64, base []byte) [][]byte {
+	return s.auditPath(s.nodes(), leafStore(d), s.N, s.Base, base, height)
+}
+
+func (s *SMT) auditPath(n nodes, d LeafStore, height uint64, base, key []byte,
+	stop uint64) [][]byte {
+	if height == stop {
 		return nil
 	}
 	split := bitSplit(base, s.N-height)
-	l, r := d.Split(split)
+	l, r := d.SplitAt(split)
 
 	if !bitIsSet(key, s.N-height) { // if k_j == 0
-		return append(s.AuditPath(l, height-1, base, key),
-			s.RootHash(r, height-1, split))
+		return append(s.auditPath(n, l, height-1, base, key, stop),
+			s.rootHash(n, r, height-1, split))
 	}
-	return append(s.AuditPath(r, height-1, split, key),
-		s.RootHash(l, height-1, base))
+	return append(s.auditPath(n, r, height-1, split, key, stop),
+		s.rootHash(n, l, height-1, base))
 }
 
 // VerifyAuditPath verifies an audit path.
 func (s *SMT) VerifyAuditPath(ap [][]byte, key, value, root []byte) bool {
-	return bytes.Equal(root,
-		s.auditPathCalc(ap, s.N, make([]byte, s.N/8), key, value))
+	return s.verify(s.nodes(), ap, 0, key, s.leafHash(value, key), root)
+}
+
+// VerifySubtreeAuditPath verifies an audit path for the root subRoot of the
+// subtree with the provided height and base.
+func (s *SMT) VerifySubtreeAuditPath(ap [][]byte, height uint64,
+	base, subRoot, root []byte) bool {
+	return height <= s.N && s.verify(s.nodes(), ap, height, base, subRoot, root)
 }
 
-func (s *SMT) auditPathCalc(ap [][]byte, height uint64,
-	base, key, value []byte) []byte {
-	if height == 0 {
-		return s.leafHash(value, base)
+func (s *SMT) verify(n nodes, ap [][]byte, stop uint64, key, node, root []byte) bool {
+	if uint64(len(ap)) != s.N-stop {
+		return false
+	}
+	calc := s.auditPathCalc(n, ap, s.N, make([]byte, s.N/8), key, stop, node)
+	return calc != nil && bytes.Equal(root, calc)
+}
+
+func (s *SMT) auditPathCalc(n nodes, ap [][]byte, height uint64,
+	base, key []byte, stop uint64, node []byte) []byte {
+	if height == stop {
+		return node
 	}
 	split := bitSplit(base, s.N-height)
 	if !bitIsSet(key, s.N-height) { // if k_j == 0
-		return s.interiorHash(s.auditPathCalc(ap, height-1, base, key, value),
-			ap[height-1], height, base)
+		return n.interior(s.auditPathCalc(n, ap, height-1, base, key, stop, node),
+			ap[height-stop-1], height, base)
 	}
-	return s.interiorHash(ap[height-1],
-		s.auditPathCalc(ap, height-1, split, key, value), height, base)
+	return n.interior(ap[height-stop-1],
+		s.auditPathCalc(n, ap, height-1, split, key, stop, node), height, base)
 }
 
 // RootHash returns the root hash of a subtree with certain height.
-func (s *SMT) RootHash(d D, height uint64, base []byte) []byte {
+func (s *SMT) RootHash(d LeafStore, height uint64, base []byte) []byte {
+	return s.rootHash(s.nodes(), leafStore(d), height, base)
+}
+
+func (s *SMT) rootHash(n nodes, d LeafStore, height uint64, base []byte) []byte {
 	switch {
 	case s.cache.Exists(height, base):
 		return s.cache.Get(height, base)
 	case d.Len() == 0:
-		return s.defaultHash(height)
+		return n.defaults[height]
 	case d.Len() == 1 && height == 0:
-		return s.leafHash(Set, base)
+		return n.leaf(base, Set)
 	case d.Len() > 0 && height == 0:
-		panic("this should never happen (unsorted D or broken split?)")
+		panic("this should never happen (unsorted store or broken split?)")
 	default:
 		split := bitSplit(base, s.N-height)
-		l, r := d.Split(split)
-		return s.interiorHash(s.RootHash(l, height-1, base),
-			s.RootHash(r, height-1, split), height, base)
+		l, r := d.SplitAt(split)
+		return n.interior(s.rootHash(n, l, height-1, base),
+			s.rootHash(n, r, height-1, split), height, base)
 	}
 }
 
@@ -171,12 +226,30 @@ func (s *SMT) defaultHash(height uint64) []byte {
 	return s.defaultHashes[height]
 }
 
-// leafHash returns the leaf value of SMT.
+// leafValue returns the value of the leaf with the provided key.
+func (s *SMT) leafValue(key []byte) []byte {
+	if s.Values == nil {
+		return Set
+	}
+	return s.Values.Value(key)
+}
+
+// validValue returns true if value is Set or Empty, the values that Update
+// sets leaves to.
+func validValue(value []byte) bool {
+	return bytes.Equal(value, Set) || bytes.Equal(value, Empty)
+}
+
+// leafHash returns the leaf value of SMT. Set leaves only commit to their
+// position, any other non-empty value is also bound into the leaf.
 func (s *SMT) leafHash(a, base []byte) []byte {
-	if bytes.Equal(a, Empty) {
+	switch {
+	case bytes.Equal(a, Empty):
 		return s.hash(s.c)
+	case bytes.Equal(a, Set):
+		return s.hash(s.c, base)
 	}
-	return s.hash(s.c, base)
+	return s.hash(s.c, base, a)
 }
 
 // interiorHash returns the non-leaf node value of SMT.
@@ -196,3 +269,12 @@ func (s *SMT) interiorHash(left, right []byte,
 func (s *SMT) CacheEntries() int {
 	return s.cache.Entries()
 }
+
+// CacheSize returns an estimate of the memory used by the cache in bytes. For
+// a Cache that is not a CacheSizer, it only counts the hash of each entry.
+func (s *SMT) CacheSize() int {
+	if c, ok := s.cache.(CacheSizer); ok {
+		return c.Size()
+	}
+	return s.cache.Entries() * int(s.N/8)
+}
@@ -3,7 +3,6 @@ package gosmt
 import (
 	"bytes"
 	"math/rand"
-	"sort"
 	"testing"
 )
 
@@ -31,8 +30,7 @@ func TestCaching(t *testing.T) {
 		roots := make([][]byte, len(s))
 
 		keys = getFreshData(roundSize)
-		data = append(data, keys...)
-		sort.Sort(D(data))
+		data = data.Insert(keys.All()...)
 
 		for i := 0; i < len(s); i++ {
 			// update, then make sure we get the same root from RootHash
@@ -62,8 +60,8 @@ func TestCaching(t *testing.T) {
 
 		// remove n random keys from the tail of data (we know they're (still) sorted)
 		n := roundSize / 2
-		removedKeys := Key(data[len(data)-n:])
-		data = data[:len(data)-n]
+		removedKeys := data.Slice(data.Len()-n, data.Len())
+		data = data.Slice(0, data.Len()-n)
 
 		for i := 0; i < len(s); i++ {
 			// update, then make sure we get the same root from RootHash
@@ -94,7 +92,7 @@ func TestCaching(t *testing.T) {
 }
 
 func getFreshData(size int) Key {
-	var data Key
+	var data [][]byte
 	for i := 0; i < size; i++ {
 		key := make([]byte, 32)
 		_, err := rand.Read(key)
@@ -103,6 +101,139 @@ func getFreshData(size int) Key {
 		}
 		data = append(data, hash(key))
 	}
-	sort.Sort(Key(data))
-	return data
+	return NewKeys(data...)
+}
+
+func TestSubtreeAuditPath(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	data := getFreshData(32)
+	root := s.Update(data, data, s.N, s.Base, Set)
+
+	// the subtree of all keys with the first bit set
+	height := s.N - 1
+	base := bitSplit(s.Base, 0)
+	_, sub := data.Split(base)
+	subRoot := s.RootHash(sub, height, base)
+
+	ap := s.SubtreeAuditPath(data, height, base)
+	if !s.VerifySubtreeAuditPath(ap, height, base, subRoot, root) {
+		t.Fatal("failed to verify valid subtree proof")
+	}
+	if s.VerifySubtreeAuditPath(ap, height, s.Base, subRoot, root) {
+		t.Fatal("verified subtree proof for the wrong subtree")
+	}
+
+	// stitch a proof from within the subtree with the subtree proof
+	for _, key := range sub.All() {
+		inner := s.AuditPath(sub, height, base, key)
+		if !s.VerifyAuditPath(append(inner, ap...), key, Set, root) {
+			t.Fatal("failed to verify stitched proof")
+		}
+	}
+}
+
+func TestBulkLoad(t *testing.T) {
+	data := getFreshData(256)
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	root := s.Update(data, data, s.N, s.Base, Set)
+
+	bulk := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	if !bytes.Equal(root, bulk.BulkLoad(data)) {
+		t.Fatal("roots mismatch")
+	}
+	if bulk.CacheEntries() != s.CacheEntries() {
+		t.Fatal("cache filled differently than by Update")
+	}
+
+	// the bulk-loaded tree can be updated
+	keys := getFreshData(8)
+	data = data.Insert(keys.All()...)
+	if !bytes.Equal(s.Update(data, keys, s.N, s.Base, Set),
+		bulk.Update(data, keys, s.N, s.Base, Set)) {
+		t.Fatal("roots mismatch after update")
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	data := getFreshData(64)
+	var sizes []int
+	for _, cache := range []Cache{CacheNothing(0),
+		CacheBranch(make(map[string][]byte)),
+		CacheBranchPlus(make(map[string][]byte))} {
+		s := NewSMT([]byte{0x42}, cache, hash)
+		if s.CacheEntries() == 0 && s.CacheSize() > mapHeaderSize {
+			t.Fatal("empty cache with entries in its size")
+		}
+		s.BulkLoad(data)
+		// the hashes alone take N/8 bytes per entry
+		if s.CacheSize() < s.CacheEntries()*int(s.N/8) {
+			t.Fatalf("cache size %d below the size of %d hashes",
+				s.CacheSize(), s.CacheEntries())
+		}
+		sizes = append(sizes, s.CacheSize())
+	}
+	if sizes[0] != 0 || sizes[1] >= sizes[2] {
+		t.Fatalf("unexpected cache sizes %v", sizes)
+	}
+	// a Cache that is not a CacheSizer, e.g., of another package
+	s := NewSMT([]byte{0x42}, struct{ Cache }{CacheBranch(make(map[string][]byte))}, hash)
+	s.BulkLoad(data)
+	if s.CacheSize() != s.CacheEntries()*int(s.N/8) {
+		t.Fatalf("cache size %d not the size of %d hashes",
+			s.CacheSize(), s.CacheEntries())
+	}
+}
+
+func TestUnsortedKeys(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	ref := NewSMT([]byte{0x42}, CacheNothing(0), hash)
+
+	data := getFreshData(64)
+	raw := [][]byte{data.At(60), data.At(51), data.At(63), data.At(50), data.At(55)}
+	keys := NewKeys(raw...)
+	root := s.Update(data, keys, s.N, s.Base, Set)
+	if !bytes.Equal(root, ref.RootHash(data, ref.N, ref.Base)) {
+		t.Fatal("wrong root for unsorted keys")
+	}
+	unsorted := NewKeys(data.At(3), data.At(1), data.At(2), data.At(0))
+	if !bytes.Equal(ref.RootHash(unsorted, ref.N, ref.Base),
+		ref.RootHash(data.Slice(0, 4), ref.N, ref.Base)) {
+		t.Fatal("wrong root for unsorted D")
+	}
+	ap := ref.AuditPath(unsorted, ref.N, ref.Base, data.At(2))
+	if !ref.VerifyAuditPath(ap, data.At(2), Set,
+		ref.RootHash(unsorted, ref.N, ref.Base)) {
+		t.Fatal("failed to verify valid proof in unsorted D")
+	}
+	if !bytes.Equal(raw[0], data.At(60)) {
+		t.Fatal("keys sorted in place")
+	}
+}
+
+func TestCustomValue(t *testing.T) {
+	values := make(mapValues)
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	s.Values = values
+	data := getFreshData(32)
+	root := s.BulkLoad(data)
+
+	// other values are only committed to through Values
+	if s.Update(data, data.Slice(0, 1), s.N, s.Base, []byte("custom")) != nil {
+		t.Fatal("updated to a value other than Set or Empty")
+	}
+	if !bytes.Equal(root, s.RootHash(data, s.N, s.Base)) {
+		t.Fatal("rejected update changed the tree")
+	}
+	values[string(data.At(0))] = []byte("custom")
+	root = s.Update(data, data.Slice(0, 1), s.N, s.Base, Set)
+	ref := NewSMT([]byte{0x42}, CacheNothing(0), hash)
+	ref.Values = values
+	if !bytes.Equal(root, s.RootHash(data, s.N, s.Base)) ||
+		!bytes.Equal(root, ref.RootHash(data, ref.N, ref.Base)) {
+		t.Fatal("roots mismatch for a custom value")
+	}
+	ap := s.AuditPath(data, s.N, s.Base, data.At(0))
+	if !s.VerifyAuditPath(ap, data.At(0), []byte("custom"), root) {
+		t.Fatal("failed to verify custom value")
+	}
 }
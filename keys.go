@@ -0,0 +1,130 @@
+package gosmt
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Keys is a set of keys in ascending order, used both for D and Key. It is a
+// Trie and a LeafStore. Keys can only be created by NewKeys, Insert, Remove
+// and Slice, which keep it sorted and free of duplicates, so splitting is a
+// binary search and never has to sort or check the order. The zero value is
+// empty.
+type Keys[K ~[]byte] struct {
+	keys []K
+}
+
+// compile-time check that D is a Trie
+var _ Trie[D] = D{}
+
+// NewKeys creates new sorted Keys from keys, removing duplicates.
+func NewKeys[K ~[]byte](keys ...K) Keys[K] {
+	k := append([]K{}, keys...)
+	sort.Slice(k, func(i, j int) bool { return bytes.Compare(k[i], k[j]) < 0 })
+	return Keys[K]{dedup(k)}
+}
+
+// Len returns the number of keys.
+func (k Keys[K]) Len() int { return len(k.keys) }
+
+// At returns the key with index i.
+func (k Keys[K]) At(i int) K { return k.keys[i] }
+
+// Slice returns the keys with index [i, j).
+func (k Keys[K]) Slice(i, j int) Keys[K] { return Keys[K]{k.keys[i:j]} }
+
+// All returns a copy of all keys in ascending order.
+func (k Keys[K]) All() []K { return append([]K{}, k.keys...) }
+
+// Split splits k based on Split index s.
+func (k Keys[K]) Split(s []byte) (l, r Keys[K]) {
+	i := k.search(s)
+	return Keys[K]{k.keys[:i]}, Keys[K]{k.keys[i:]}
+}
+
+// SplitAt splits k based on split index s, as a LeafStore.
+func (k Keys[K]) SplitAt(s []byte) (l, r LeafStore) {
+	return k.Split(s)
+}
+
+// Count returns the number of keys x in k with lo <= x < hi, where a nil hi
+// is the end of the key space.
+func (k Keys[K]) Count(lo, hi []byte) int {
+	if hi == nil {
+		return k.Len() - k.search(lo)
+	}
+	if bytes.Compare(lo, hi) >= 0 {
+		return 0
+	}
+	return k.search(hi) - k.search(lo)
+}
+
+// Iterate calls f with each key in k until f returns false.
+func (k Keys[K]) Iterate(f func(key []byte) bool) {
+	for _, key := range k.keys {
+		if !f([]byte(key)) {
+			return
+		}
+	}
+}
+
+// Insert returns new Keys with k and keys, sorted and free of duplicates.
+func (k Keys[K]) Insert(keys ...K) Keys[K] {
+	add := NewKeys(keys...).keys
+	merged := make([]K, 0, len(k.keys)+len(add))
+	i, j := 0, 0
+	for i < len(k.keys) && j < len(add) {
+		switch bytes.Compare(k.keys[i], add[j]) {
+		case -1:
+			merged = append(merged, k.keys[i])
+			i++
+		case 1:
+			merged = append(merged, add[j])
+			j++
+		default:
+			merged = append(merged, k.keys[i])
+			i++
+			j++
+		}
+	}
+	merged = append(merged, k.keys[i:]...)
+	return Keys[K]{append(merged, add[j:]...)}
+}
+
+// Remove returns new Keys with k except keys.
+func (k Keys[K]) Remove(keys ...K) Keys[K] {
+	remove := NewKeys(keys...).keys
+	kept := make([]K, 0, len(k.keys))
+	j := 0
+	for _, key := range k.keys {
+		for j < len(remove) && bytes.Compare(remove[j], key) < 0 {
+			j++
+		}
+		if j < len(remove) && bytes.Equal(remove[j], key) {
+			continue
+		}
+		kept = append(kept, key)
+	}
+	return Keys[K]{kept}
+}
+
+// search returns the smallest index i where k.keys[i] >= s.
+func (k Keys[K]) search(s []byte) int {
+	return sort.Search(k.Len(), func(i int) bool {
+		return bytes.Compare(k.keys[i], s) >= 0
+	})
+}
+
+// dedup removes duplicates from sorted keys in place.
+func dedup[K ~[]byte](keys []K) []K {
+	if len(keys) == 0 {
+		return keys
+	}
+	out := keys[:1]
+	for _, key := range keys[1:] {
+		if !bytes.Equal(out[len(out)-1], key) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
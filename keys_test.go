@@ -0,0 +1,72 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	fresh := getFreshData(64)
+	shuffled := fresh.All()
+	shuffled[0], shuffled[63] = shuffled[63], shuffled[0]
+	shuffled = append(shuffled, fresh.At(10), fresh.At(20))
+
+	k := NewKeys(shuffled...)
+	if !ascending(k) || k.Len() != 64 {
+		t.Fatal("NewKeys did not sort or remove duplicates")
+	}
+	if !bytes.Equal(shuffled[0], fresh.At(63)) {
+		t.Fatal("NewKeys sorted its argument in place")
+	}
+	for i := 0; i < k.Len(); i++ {
+		if !bytes.Equal(k.At(i), fresh.At(i)) {
+			t.Fatal("keys mismatch")
+		}
+	}
+
+	more := getFreshData(16)
+	k = k.Insert(append(more.All(), fresh.At(0))...)
+	if !ascending(k) || k.Len() != 80 {
+		t.Fatal("Insert did not keep keys sorted and free of duplicates")
+	}
+	k = k.Remove(append(more.All(), hash([]byte("non-member")))...)
+	if k.Len() != 64 {
+		t.Fatal("Remove removed the wrong keys")
+	}
+	for i := 0; i < k.Len(); i++ {
+		if !bytes.Equal(k.At(i), fresh.At(i)) {
+			t.Fatal("keys mismatch")
+		}
+	}
+
+	// any Trie splits without sorting
+	var trie Trie[D] = k
+	l, r := trie.Split(fresh.At(32))
+	if l.Len() != 32 || r.Len() != 32 || !bytes.Equal(r.At(0), fresh.At(32)) {
+		t.Fatal("wrong split")
+	}
+
+	// the zero value is empty, and All returns a copy
+	var zero D
+	l, r = zero.Split(fresh.At(32))
+	if l.Len() != 0 || r.Len() != 0 || zero.Count(fresh.At(0), nil) != 0 {
+		t.Fatal("zero value not empty")
+	}
+	all := k.All()
+	all[0] = all[1]
+	if bytes.Equal(k.At(0), k.At(1)) {
+		t.Fatal("All shares the keys")
+	}
+}
+
+// ascending returns true if the keys of d are in strictly ascending order.
+func ascending(d D) bool {
+	var prev []byte
+	ok := true
+	d.Iterate(func(key []byte) bool {
+		ok = prev == nil || bytes.Compare(prev, key) < 0
+		prev = key
+		return ok
+	})
+	return ok
+}
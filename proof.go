@@ -0,0 +1,40 @@
+package gosmt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+)
+
+// Proof is a membership, or non-membership, proof for a key: an audit path
+// for the value of the leaf of the key against a signed root.
+type Proof struct {
+	Key       []byte
+	Value     []byte // the value of the leaf, Empty for non-members
+	AuditPath [][]byte
+	Root      *SignedRoot
+}
+
+// Prove generates a proof for key in the SMT with the keys d. The root of the
+// proof is left for the caller to set.
+func (s *SMT) Prove(d LeafStore, key []byte) *Proof {
+	d = leafStore(d)
+	p := &Proof{Key: key, Value: Empty}
+	if d.Count(key, bitIncrement(key)) == 1 {
+		p.Value = s.leafValue(key)
+	}
+	p.AuditPath = s.AuditPath(d, s.N, s.Base, key)
+	return p
+}
+
+// Member returns true if the proof is a membership proof.
+func (p *Proof) Member() bool {
+	return !bytes.Equal(p.Value, Empty)
+}
+
+// VerifyProof verifies the signature of the root of a proof with pub, and the
+// audit path of the proof against that root.
+func (s *SMT) VerifyProof(p *Proof, pub ed25519.PublicKey) bool {
+	return p.Root != nil && p.Root.Verify(pub) &&
+		uint64(len(p.Key)) == s.N/8 &&
+		s.VerifyAuditPath(p.AuditPath, p.Key, p.Value, p.Root.Root)
+}
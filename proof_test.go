@@ -0,0 +1,35 @@
+package gosmt
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestProof(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	data := getFreshData(32)
+	root := SignRoot(priv, 1, s.BulkLoad(data))
+
+	member := s.Prove(data, data.At(5))
+	nonMember := s.Prove(data, hash([]byte("non-member")))
+	member.Root, nonMember.Root = root, root
+	if !member.Member() || nonMember.Member() {
+		t.Fatal("wrong membership")
+	}
+	if !s.VerifyProof(member, pub) || !s.VerifyProof(nonMember, pub) {
+		t.Fatal("failed to verify valid proof")
+	}
+
+	member.Value = Empty
+	if s.VerifyProof(member, pub) {
+		t.Fatal("verified non-membership of member")
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+	if s.VerifyProof(nonMember, other) {
+		t.Fatal("verified proof with root signed by someone else")
+	}
+}
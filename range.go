@@ -0,0 +1,119 @@
+package gosmt
+
+import "bytes"
+
+// RangeProof proves the complete set of keys in a range of a SMT.
+type RangeProof struct {
+	// Keys are all keys in the range, in ascending order.
+	Keys [][]byte
+	// Siblings are the root hashes of the largest subtrees outside of the
+	// range, in the left-to-right order they are visited from the root.
+	Siblings [][]byte
+}
+
+// RangeProof generates a proof of all keys k in d with lo <= k < hi, where a
+// nil hi is the end of the key space.
+func (s *SMT) RangeProof(d LeafStore, lo, hi []byte) *RangeProof {
+	p := new(RangeProof)
+	s.rangeProof(s.nodes(), leafStore(d), s.N, s.Base, lo, hi, p)
+	return p
+}
+
+// PrefixProof generates a proof of all keys in the subtree with the provided
+// height and base, i.e., all keys starting with the first N-height bits of base.
+func (s *SMT) PrefixProof(d LeafStore, height uint64, base []byte) *RangeProof {
+	return s.RangeProof(d, base, s.subtreeEnd(height, base))
+}
+
+func (s *SMT) rangeProof(n nodes, d LeafStore, height uint64, base, lo, hi []byte,
+	p *RangeProof) {
+	last := bitFill(base, s.N-height)
+	switch {
+	case !overlaps(base, last, lo, hi):
+		p.Siblings = append(p.Siblings, s.rootHash(n, d, height, base))
+	case contains(base, last, lo, hi):
+		d.Iterate(func(key []byte) bool {
+			p.Keys = append(p.Keys, key)
+			return true
+		})
+	default:
+		split := bitSplit(base, s.N-height)
+		l, r := d.SplitAt(split)
+		s.rangeProof(n, l, height-1, base, lo, hi, p)
+		s.rangeProof(n, r, height-1, split, lo, hi, p)
+	}
+}
+
+// VerifyRangeProof verifies that the keys of a range proof are all keys k
+// with lo <= k < hi in the SMT with the provided root.
+func (s *SMT) VerifyRangeProof(p *RangeProof, lo, hi, root []byte) bool {
+	for i := range p.Keys {
+		if uint64(len(p.Keys[i])) != s.N/8 ||
+			!contains(p.Keys[i], p.Keys[i], lo, hi) ||
+			(i > 0 && bytes.Compare(p.Keys[i-1], p.Keys[i]) >= 0) {
+			return false
+		}
+	}
+	keys, siblings := NewKeys(p.Keys...), p.Siblings
+	r := s.verifier().rangeProofCalc(&keys, &siblings, s.N, s.Base, lo, hi)
+	return r != nil && keys.Len() == 0 && len(siblings) == 0 &&
+		bytes.Equal(r, root)
+}
+
+// VerifyPrefixProof verifies that the keys of a prefix proof are all keys in
+// the subtree with the provided height and base.
+func (s *SMT) VerifyPrefixProof(p *RangeProof, height uint64,
+	base, root []byte) bool {
+	return s.VerifyRangeProof(p, base, s.subtreeEnd(height, base), root)
+}
+
+func (s *SMT) rangeProofCalc(keys *D, siblings *[][]byte, height uint64,
+	base, lo, hi []byte) []byte {
+	last := bitFill(base, s.N-height)
+	switch {
+	case !overlaps(base, last, lo, hi):
+		if len(*siblings) == 0 {
+			return nil
+		}
+		r := (*siblings)[0]
+		*siblings = (*siblings)[1:]
+		return r
+	case contains(base, last, lo, hi):
+		i := 0
+		for i < keys.Len() && bytes.Compare(keys.At(i), last) <= 0 {
+			i++
+		}
+		r := s.RootHash(keys.Slice(0, i), height, base)
+		*keys = keys.Slice(i, keys.Len())
+		return r
+	default:
+		split := bitSplit(base, s.N-height)
+		l := s.rangeProofCalc(keys, siblings, height-1, base, lo, hi)
+		r := s.rangeProofCalc(keys, siblings, height-1, split, lo, hi)
+		if l == nil || r == nil {
+			return nil
+		}
+		return s.interiorHash(l, r, height, base)
+	}
+}
+
+// verifier returns a copy of s that caches nothing, for computing the nodes
+// of a SMT from a proof.
+func (s *SMT) verifier() *SMT {
+	v := *s
+	v.cache = CacheNothing(0)
+	v.gen = new(uint64)
+	return &v
+}
+
+// overlaps checks if the range [first, last] overlaps with [lo, hi).
+func overlaps(first, last, lo, hi []byte) bool {
+	return bytes.Compare(last, lo) >= 0 &&
+		(hi == nil || bytes.Compare(first, hi) < 0)
+}
+
+// contains checks if the range [first, last] is within [lo, hi).
+func contains(first, last, lo, hi []byte) bool {
+	return bytes.Compare(first, lo) >= 0 &&
+		(hi == nil || bytes.Compare(last, hi) < 0)
+}
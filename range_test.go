@@ -0,0 +1,77 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRangeProof(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	data := getFreshData(64)
+	root := s.Update(data, data, s.N, s.Base, Set)
+
+	lo, hi := data.At(10), data.At(20)
+	p := s.RangeProof(data, lo, hi)
+	if len(p.Keys) != 10 || !bytes.Equal(p.Keys[0], lo) {
+		t.Fatalf("expected 10 keys in range, got %d", len(p.Keys))
+	}
+	if !s.VerifyRangeProof(p, lo, hi, root) {
+		t.Fatal("failed to verify valid range proof")
+	}
+
+	// omitting a key must fail
+	omitted := &RangeProof{Keys: p.Keys[1:], Siblings: p.Siblings}
+	if s.VerifyRangeProof(omitted, lo, hi, root) {
+		t.Fatal("verified range proof with a missing key")
+	}
+	// adding a key out of range must fail
+	added := &RangeProof{Keys: append(p.Keys[:10:10], hi), Siblings: p.Siblings}
+	if s.VerifyRangeProof(added, lo, hi, root) {
+		t.Fatal("verified range proof with a key out of range")
+	}
+
+	// the whole tree, and the empty range
+	p = s.RangeProof(data, s.Base, nil)
+	if len(p.Keys) != data.Len() || !s.VerifyRangeProof(p, s.Base, nil, root) {
+		t.Fatal("failed to verify range proof for the whole tree")
+	}
+	p = s.RangeProof(data, lo, lo)
+	if len(p.Keys) != 0 || !s.VerifyRangeProof(p, lo, lo, root) {
+		t.Fatal("failed to verify empty range proof")
+	}
+
+	// every prefix of two bits
+	var total int
+	for i := byte(0); i < 4; i++ {
+		base := make([]byte, s.N/8)
+		base[0] = i << 6
+		p = s.PrefixProof(data, s.N-2, base)
+		for _, k := range p.Keys {
+			if k[0]>>6 != i {
+				t.Fatal("key not in prefix")
+			}
+		}
+		if !s.VerifyPrefixProof(p, s.N-2, base, root) {
+			t.Fatalf("failed to verify valid prefix proof for %d", i)
+		}
+		total += len(p.Keys)
+	}
+	if total != data.Len() {
+		t.Fatal("prefix proofs do not cover all keys")
+	}
+
+	// keys given in any order, with duplicates, give the same proof
+	raw := data.All()
+	for i := range raw {
+		j := len(raw) - 1 - i
+		if i < j {
+			raw[i], raw[j] = raw[j], raw[i]
+		}
+	}
+	unsorted := NewKeys(append(raw, data.At(15))...)
+	p = s.RangeProof(unsorted, lo, hi)
+	if len(p.Keys) != 10 || !bytes.Equal(p.Keys[0], lo) ||
+		!s.VerifyRangeProof(p, lo, hi, root) {
+		t.Fatal("failed to verify range proof from unsorted keys")
+	}
+}
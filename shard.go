@@ -0,0 +1,203 @@
+package gosmt
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+// MaxShardBits is the maximum k of a ShardedSMT, i.e., at most 2^16 shards.
+const MaxShardBits = 16
+
+// ShardedSMT is a SMT where the key space is split by the top k bits of keys
+// into 2^k independent shards. Each shard is a SMT with its own D and cache,
+// holding the subtree of height N-k of its keys. The shard roots are combined
+// with the interiorHash of the SMT at the top k heights, so the root and
+// audit paths are identical to those of a single SMT with all keys.
+type ShardedSMT struct {
+	sync.RWMutex // held to write by Update, to read by everything else
+	k            uint64
+	top          *SMT // computes the nodes above the shards
+	shards       []*shard
+	// nodes are the nodes of the top k heights, updated together with the
+	// shard roots: nodes[1] is the root, the children of nodes[i] are
+	// nodes[2i] and nodes[2i+1], and nodes[1<<k+i] is the root of shard i.
+	nodes [][]byte
+	Base  []byte // key of left-most leaf of a subtree, fixed in size.
+	N     uint64 // output length, in bits, of hash
+}
+
+type shard struct {
+	smt  *SMT
+	d    D
+	base []byte
+}
+
+// NewShardedSMT creates a new ShardedSMT with 2^k shards. Like for NewSMT, it
+// requires a default empty leaf constant c and a hash function. Each shard
+// gets its own cache from the provided function (e.g., CacheBranch). Returns
+// an error if k exceeds MaxShardBits or the bits in the hash output.
+func NewShardedSMT(c []byte, k uint64, cache func() Cache,
+	hash func(data ...[]byte) []byte) (*ShardedSMT, error) {
+	if k > MaxShardBits {
+		return nil, errors.New("too many shard bits")
+	}
+	s := new(ShardedSMT)
+	s.k = k
+	s.top = NewSMT(c, CacheNothing(0), hash)
+	s.Base = s.top.Base
+	s.N = s.top.N
+	if k > s.N {
+		return nil, errors.New("more shard bits than bits in the hash output")
+	}
+
+	s.shards = make([]*shard, 1<<k)
+	s.nodes = make([][]byte, 2<<k)
+	for i := range s.shards {
+		sh := new(shard)
+		sh.smt = NewSMT(c, cache(), hash)
+		sh.base = make([]byte, s.N/8)
+		for j := uint64(0); j < k; j++ {
+			if i&(1<<(k-1-j)) != 0 {
+				bitSet(sh.base, j)
+			}
+		}
+		s.shards[i] = sh
+	}
+	// all nodes of an empty tree are defaults
+	for i, height := 1, s.N; i < len(s.nodes); i, height = i<<1, height-1 {
+		for j := i; j < i<<1; j++ {
+			s.nodes[j] = s.top.defaultHash(height)
+		}
+	}
+	return s, nil
+}
+
+// Update updates keys to the value, adding or removing (for Empty) the keys
+// from the D of their shards. Shards are updated concurrently, then the
+// nodes above them. Returns the new root, or nil without updating for a value
+// other than Set or Empty.
+func (s *ShardedSMT) Update(keys Key, value []byte) []byte {
+	if !validValue(value) {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	var wg sync.WaitGroup
+	var updated []int // indices of the updated nodes at the current height
+	for i := 0; i < keys.Len(); {
+		index := s.shardOf(keys.At(i))
+		j := i + 1
+		for j < keys.Len() && s.shardOf(keys.At(j)) == index {
+			j++
+		}
+		updated = append(updated, 1<<s.k+index)
+
+		wg.Add(1)
+		go func(index int, keys Key) {
+			defer wg.Done()
+			sh := s.shards[index]
+			if bytes.Equal(value, Empty) {
+				sh.d = sh.d.Remove(keys.All()...)
+			} else {
+				sh.d = sh.d.Insert(keys.All()...)
+			}
+			// each goroutine writes the node of its own shard
+			s.nodes[1<<s.k+index] = sh.smt.Update(sh.d, keys, s.N-s.k, sh.base, value)
+		}(index, keys.Slice(i, j))
+		i = j
+	}
+	wg.Wait()
+
+	// recompute the nodes above the updated shards, level by level
+	for height := s.N - s.k + 1; height <= s.N; height++ {
+		var parents []int
+		for _, i := range updated {
+			i >>= 1
+			if len(parents) == 0 || parents[len(parents)-1] != i {
+				parents = append(parents, i)
+			}
+		}
+		for _, i := range parents {
+			s.nodes[i] = s.top.interiorHash(s.nodes[2*i], s.nodes[2*i+1],
+				height, s.nodeBase(i, height))
+		}
+		updated = parents
+	}
+	return s.nodes[1]
+}
+
+// RootHash returns the root hash.
+func (s *ShardedSMT) RootHash() []byte {
+	s.RLock()
+	defer s.RUnlock()
+	return s.nodes[1]
+}
+
+// AuditPath generates an audit path for key and returns it with the root it
+// is for. The path within the shard of key is generated by the shard, the rest
+// from the nodes above the shards, all of the same version of the tree.
+func (s *ShardedSMT) AuditPath(key []byte) (ap [][]byte, root []byte) {
+	s.RLock()
+	defer s.RUnlock()
+	index := s.shardOf(key)
+	sh := s.shards[index]
+	ap = sh.smt.AuditPath(sh.d, s.N-s.k, sh.base, key)
+	for i := 1<<s.k + index; i > 1; i >>= 1 {
+		ap = append(ap, s.nodes[i^1])
+	}
+	return ap, s.nodes[1]
+}
+
+// nodeBase returns the base of the node nodes[i] at height.
+func (s *ShardedSMT) nodeBase(i int, height uint64) []byte {
+	base := make([]byte, s.N/8)
+	depth := s.N - height
+	for j := uint64(0); j < depth; j++ {
+		if i&(1<<(depth-1-j)) != 0 {
+			bitSet(base, j)
+		}
+	}
+	return base
+}
+
+// VerifyAuditPath verifies an audit path.
+func (s *ShardedSMT) VerifyAuditPath(ap [][]byte, key, value, root []byte) bool {
+	return s.top.VerifyAuditPath(ap, key, value, root)
+}
+
+// CacheEntries returns the number of cache entries of all shards.
+func (s *ShardedSMT) CacheEntries() int {
+	s.RLock()
+	defer s.RUnlock()
+	var entries int
+	for _, sh := range s.shards {
+		entries += sh.smt.CacheEntries()
+	}
+	return entries
+}
+
+// CacheSize returns an estimate of the memory used by the caches of all
+// shards in bytes.
+func (s *ShardedSMT) CacheSize() int {
+	s.RLock()
+	defer s.RUnlock()
+	var size int
+	for _, sh := range s.shards {
+		size += sh.smt.CacheSize()
+	}
+	return size
+}
+
+// shardOf returns the index of the shard of key, given by the top k bits of
+// key.
+func (s *ShardedSMT) shardOf(key []byte) int {
+	var i int
+	for j := uint64(0); j < s.k; j++ {
+		i <<= 1
+		if bitIsSet(key, j) {
+			i |= 1
+		}
+	}
+	return i
+}
@@ -0,0 +1,107 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShardedSMT(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	sharded, err := NewShardedSMT([]byte{0x42}, 3, func() Cache {
+		return CacheBranch(make(map[string][]byte))
+	}, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s.RootHash(nil, s.N, s.Base), sharded.RootHash()) {
+		t.Fatal("roots of empty trees mismatch")
+	}
+
+	data := getFreshData(64)
+	root := s.Update(data, data, s.N, s.Base, Set)
+	if !bytes.Equal(root, sharded.Update(data, Set)) {
+		t.Fatal("roots mismatch after adding keys")
+	}
+
+	check := func(key, value []byte) {
+		ap := s.AuditPath(data, s.N, s.Base, key)
+		sap, sroot := sharded.AuditPath(key)
+		if !bytes.Equal(root, sroot) {
+			t.Fatal("audit path for another root")
+		}
+		if len(ap) != len(sap) {
+			t.Fatal("audit path lengths mismatch")
+		}
+		for i := range ap {
+			if !bytes.Equal(ap[i], sap[i]) {
+				t.Fatal("audit paths mismatch")
+			}
+		}
+		if !sharded.VerifyAuditPath(sap, key, value, root) {
+			t.Fatal("failed to verify valid proof")
+		}
+	}
+	for _, key := range data.All() {
+		check(key, Set)
+	}
+	check(hash([]byte("non-member")), Empty)
+
+	// remove every other key
+	var even [][]byte
+	for i := 0; i < data.Len(); i += 2 {
+		even = append(even, data.At(i))
+	}
+	removed := NewKeys(even...)
+	data = data.Remove(even...)
+	root = s.Update(data, removed, s.N, s.Base, Empty)
+	if !bytes.Equal(root, sharded.Update(removed, Empty)) {
+		t.Fatal("roots mismatch after removing keys")
+	}
+	check(removed.At(0), Empty)
+	check(data.At(0), Set)
+
+	// keys given in any order are sorted by NewKeys
+	unsorted := NewKeys(removed.At(2), removed.At(0), removed.At(1))
+	root = s.Update(data.Insert(unsorted.All()...), unsorted, s.N, s.Base, Set)
+	if !bytes.Equal(root, sharded.Update(unsorted, Set)) {
+		t.Fatal("roots mismatch after adding unsorted keys")
+	}
+
+	if _, err := NewShardedSMT([]byte{0x42}, MaxShardBits+1, func() Cache {
+		return CacheNothing(0)
+	}, hash); err == nil {
+		t.Fatal("expected error for too many shard bits")
+	}
+}
+
+func TestShardedSMTConcurrent(t *testing.T) {
+	sharded, err := NewShardedSMT([]byte{0x42}, 2, func() Cache {
+		return CacheBranch(make(map[string][]byte))
+	}, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharded.Update(getFreshData(64), Set)
+	key := hash([]byte("non-member"))
+
+	// every audit path verifies against the root returned with it, while
+	// the other shards are updated concurrently
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 32; i++ {
+			sharded.Update(getFreshData(8), Set)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		ap, root := sharded.AuditPath(key)
+		if !sharded.VerifyAuditPath(ap, key, Empty, root) {
+			t.Fatal("audit path inconsistent with its root")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package gosmt
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+)
+
+// signedRootContext separates signatures on roots from other signatures.
+var signedRootContext = []byte("gosmt signed root")
+
+// SignedRoot is a root of a version of a SMT, signed by the replica serving it.
+type SignedRoot struct {
+	Version   uint64
+	Root      []byte
+	Signature []byte
+}
+
+// SignRoot signs the root of the provided version with priv.
+func SignRoot(priv ed25519.PrivateKey, version uint64, root []byte) *SignedRoot {
+	sr := &SignedRoot{Version: version, Root: root}
+	sr.Signature = ed25519.Sign(priv, sr.message())
+	return sr
+}
+
+// Verify verifies the signature of the root with pub.
+func (sr *SignedRoot) Verify(pub ed25519.PublicKey) bool {
+	return len(pub) == ed25519.PublicKeySize &&
+		ed25519.Verify(pub, sr.message(), sr.Signature)
+}
+
+func (sr *SignedRoot) message() []byte {
+	msg := append([]byte{}, signedRootContext...)
+	msg = binary.BigEndian.AppendUint64(msg, sr.Version)
+	return append(msg, sr.Root...)
+}
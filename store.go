@@ -0,0 +1,86 @@
+package gosmt
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// LeafStore is a sorted set of keys, the leaves of a SMT that are set. A SMT
+// queries it for the leaves of subtrees that are not in its cache, so it can
+// be backed by something other than memory, e.g., a BTree or a FileStore.
+// Keys, and hence D, is a LeafStore. A nil LeafStore is empty.
+type LeafStore interface {
+	// Len returns the number of keys in the store.
+	Len() int
+	// Count returns the number of keys k in the store with lo <= k < hi,
+	// where a nil hi is the end of the key space.
+	Count(lo, hi []byte) int
+	// SplitAt splits the store into the keys smaller than s and the rest.
+	SplitAt(s []byte) (l, r LeafStore)
+	// Iterate calls f with each key in ascending order until f returns false.
+	Iterate(f func(key []byte) bool)
+}
+
+// leafStore returns d, or an empty store for a nil d.
+func leafStore(d LeafStore) LeafStore {
+	if d == nil {
+		return D{}
+	}
+	return d
+}
+
+// WriteKeys writes all keys in store to w as concatenated keys, the format
+// read by RootHashFromReader and OpenFileStore.
+func WriteKeys(w io.Writer, store LeafStore) (err error) {
+	bw := bufio.NewWriter(w)
+	leafStore(store).Iterate(func(key []byte) bool {
+		_, err = bw.Write(key)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// maxLo returns the largest of two lower bounds, where nil is the start of
+// the key space.
+func maxLo(a, b []byte) []byte {
+	if bytes.Compare(a, b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// minHi returns the smallest of two upper bounds, where nil is the end of the
+// key space.
+func minHi(a, b []byte) []byte {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case bytes.Compare(a, b) <= 0:
+		return a
+	}
+	return b
+}
+
+// subtree returns the keys in store that are in the subtree with the
+// provided height and base.
+func (s *SMT) subtree(store LeafStore, height uint64, base []byte) LeafStore {
+	_, r := store.SplitAt(base)
+	end := s.subtreeEnd(height, base)
+	if end == nil {
+		return r
+	}
+	l, _ := r.SplitAt(end)
+	return l
+}
+
+// subtreeEnd returns the smallest key after the subtree with the provided
+// height and base, or nil at the end of the key space.
+func (s *SMT) subtreeEnd(height uint64, base []byte) []byte {
+	return bitIncrement(bitFill(base, s.N-height))
+}
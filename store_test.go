@@ -0,0 +1,233 @@
+package gosmt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"math/rand"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestUpdateStore(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	inc := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+
+	// the same updates with the keys in D and in a BTree
+	var data D
+	tree := NewBTree()
+	for round := 0; round < 4; round++ {
+		keys := getFreshData(16)
+		data = data.Insert(keys.All()...)
+		for _, k := range keys.All() {
+			tree.Insert(k)
+		}
+		root := s.Update(data, keys, s.N, s.Base, Set)
+		if !bytes.Equal(root, inc.Update(tree, keys, inc.N, inc.Base, Set)) {
+			t.Fatal("roots mismatch after adding keys")
+		}
+
+		// remove the first few keys
+		removed := data.Slice(0, 4)
+		data = data.Slice(4, data.Len())
+		for _, k := range removed.All() {
+			tree.Delete(k)
+		}
+		root = s.Update(data, removed, s.N, s.Base, Empty)
+		if !bytes.Equal(root, inc.Update(tree, removed, inc.N, inc.Base, Empty)) {
+			t.Fatal("roots mismatch after removing keys")
+		}
+		if !bytes.Equal(root, inc.Update(tree, Key{}, inc.N, inc.Base, Set)) {
+			t.Fatal("roots mismatch without keys")
+		}
+	}
+
+	// removing all keys leaves a nil store, which is empty
+	if !bytes.Equal(inc.Update(nil, data, inc.N, inc.Base, Empty), inc.defaultHash(inc.N)) {
+		t.Fatal("roots mismatch after removing all keys")
+	}
+}
+
+func TestLeafStores(t *testing.T) {
+	data := getFreshData(512)
+	tree := NewBTree(data.All()...)
+
+	path := t.TempDir() + "/keys"
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteKeys(f, data); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	file, err := OpenFileStore(path, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	s := NewSMT([]byte{0x42}, CacheNothing(0), hash)
+	root := s.RootHash(data, s.N, s.Base)
+	lo, hi := data.At(100), data.At(200)
+	key := hash([]byte("non-member"))
+	for _, store := range []LeafStore{data, tree, file} {
+		if store.Len() != data.Len() {
+			t.Fatal("wrong number of keys")
+		}
+		if store.Count(lo, hi) != 100 || store.Count(lo, nil) != data.Len()-100 {
+			t.Fatal("wrong count in range")
+		}
+		l, r := store.SplitAt(hi)
+		if l.Len() != 200 || r.Len() != data.Len()-200 || l.Count(lo, nil) != 100 {
+			t.Fatal("wrong split")
+		}
+		var i int
+		store.Iterate(func(k []byte) bool {
+			if !bytes.Equal(k, data.At(i)) {
+				t.Fatal("keys mismatch")
+			}
+			i++
+			return true
+		})
+		if i != data.Len() {
+			t.Fatal("not all keys iterated")
+		}
+
+		if !bytes.Equal(root, s.RootHash(store, s.N, s.Base)) {
+			t.Fatal("roots mismatch")
+		}
+		ap := s.AuditPath(store, s.N, s.Base, key)
+		if !s.VerifyAuditPath(ap, key, Empty, root) {
+			t.Fatal("failed to verify valid proof")
+		}
+	}
+
+	// a read error must not pass as an empty store
+	file.Close()
+	defer func() {
+		if _, ok := recover().(*FileStoreError); !ok {
+			t.Fatal("expected a FileStoreError on a closed file")
+		}
+	}()
+	s.RootHash(file, s.N, s.Base)
+	t.Fatal("computed a root from a closed file")
+}
+
+func TestBTree(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	tree := NewBTree()
+	var data D
+	for round := 0; round < 8; round++ {
+		keys := getFreshData(300)
+		for _, k := range keys.All() {
+			if !tree.Insert(k) {
+				t.Fatal("failed to insert key")
+			}
+		}
+		data = data.Insert(keys.All()...)
+		if tree.Insert(keys.At(0)) {
+			t.Fatal("inserted duplicate key")
+		}
+
+		// remove random keys, from anywhere
+		var removed [][]byte
+		for i := 0; i < data.Len(); i++ {
+			if rand.Intn(3) == 0 {
+				removed = append(removed, data.At(i))
+				if !tree.Delete(data.At(i)) {
+					t.Fatal("failed to delete key")
+				}
+			}
+		}
+		data = data.Remove(removed...)
+		if tree.Delete(removed[0]) {
+			t.Fatal("deleted missing key")
+		}
+
+		if tree.Len() != data.Len() {
+			t.Fatalf("expected %d keys, got %d", data.Len(), tree.Len())
+		}
+		var i int
+		tree.Iterate(func(k []byte) bool {
+			if !bytes.Equal(k, data.At(i)) {
+				t.Fatal("keys mismatch")
+			}
+			i++
+			return true
+		})
+		if !bytes.Equal(s.Update(tree, data, s.N, s.Base, Set),
+			s.RootHash(data, s.N, s.Base)) {
+			t.Fatal("roots mismatch")
+		}
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	empty := s.defaultHash(s.N)
+	key := hash([]byte("non-member"))
+
+	if !bytes.Equal(s.BulkLoad(nil), empty) {
+		t.Fatal("BulkLoad: wrong root for a nil store")
+	}
+	if !bytes.Equal(s.RootHash(nil, s.N, s.Base), empty) {
+		t.Fatal("RootHash: wrong root for a nil store")
+	}
+	if !s.VerifyAuditPath(s.AuditPath(nil, s.N, s.Base, key), key, Empty, empty) {
+		t.Fatal("AuditPath: invalid proof for a nil store")
+	}
+	if p := s.Prove(nil, key); p.Member() ||
+		!s.VerifyAuditPath(p.AuditPath, key, p.Value, empty) {
+		t.Fatal("Prove: invalid proof for a nil store")
+	}
+	if p := s.RangeProof(nil, s.Base, nil); len(p.Keys) != 0 ||
+		!s.VerifyRangeProof(p, s.Base, nil, empty) {
+		t.Fatal("RangeProof: invalid proof for a nil store")
+	}
+	if d, err := Diff(s, nil, s, nil); err != nil ||
+		d.Added.Len()+d.Removed.Len()+d.Changed.Len() != 0 {
+		t.Fatal("Diff: difference between nil stores")
+	}
+	var buf bytes.Buffer
+	if err = WriteKeys(&buf, nil); err != nil || buf.Len() != 0 {
+		t.Fatal("WriteKeys: wrote keys of a nil store")
+	}
+
+	// a replica with keys syncs with a peer with a nil store, and vice versa
+	data := getFreshData(4)
+	replica := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	signed := SignRoot(priv, 1, replica.BulkLoad(data))
+	for _, tc := range []struct {
+		serve, local LeafStore
+		signed       *SignedRoot
+		added        int
+		removed      int
+	}{
+		{nil, data, SignRoot(priv, 1, empty), 0, 4},
+		{data, nil, signed, 4, 0},
+	} {
+		peer, local := s, replica
+		if tc.serve != nil {
+			peer, local = replica, s
+		}
+		client, server := net.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			done <- peer.ServeSync(server, tc.serve, tc.signed)
+			server.Close()
+		}()
+		d, _, err := local.Sync(client, tc.local, pub)
+		if err == nil {
+			err = <-done
+		}
+		client.Close()
+		if err != nil || d.Added.Len() != tc.added || d.Removed.Len() != tc.removed {
+			t.Fatalf("Sync: wrong difference with a nil store: %v", err)
+		}
+	}
+}
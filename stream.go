@@ -0,0 +1,101 @@
+package gosmt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// RootHashFromReader returns the root hash of the SMT with the keys read from
+// r, e.g., a sorted file too large to load as a D. The keys must be in strictly
+// ascending order and are read as concatenated keys of N/8 bytes each. Only
+// the nodes on the path to the current key are kept in memory. If cache is not
+// nil it is filled as it would be by Update, so a SMT using it can be created
+// afterwards.
+func (s *SMT) RootHashFromReader(r io.Reader, cache Cache) ([]byte, error) {
+	kr := &keyReader{r: bufio.NewReader(r), size: int(s.N / 8)}
+	root, err := s.streamHash(kr, s.N, s.Base, cache)
+	if err != nil {
+		return nil, err
+	}
+	// all keys should have been consumed
+	if key, err := kr.peek(); err != nil {
+		return nil, err
+	} else if key != nil {
+		return nil, errors.New("keys not in strictly ascending order")
+	}
+	return root, nil
+}
+
+func (s *SMT) streamHash(kr *keyReader, height uint64, base []byte,
+	cache Cache) ([]byte, error) {
+	key, err := kr.peek()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || !bitPrefixEqual(key, base, s.N-height) {
+		return s.defaultHash(height), nil
+	}
+	if height == 0 {
+		if err := kr.pop(); err != nil {
+			return nil, err
+		}
+		return s.leafHash(s.leafValue(base), base), nil
+	}
+
+	split := bitSplit(base, s.N-height)
+	l, err := s.streamHash(kr, height-1, base, cache)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.streamHash(kr, height-1, split, cache)
+	if err != nil {
+		return nil, err
+	}
+	if cache == nil {
+		return s.interiorHash(l, r, height, base), nil
+	}
+	return cache.HashCache(l, r, height, base, split, s.interiorHash,
+		s.defaultHashes), nil
+}
+
+// keyReader reads fixed-size keys in strictly ascending order.
+type keyReader struct {
+	r    *bufio.Reader
+	size int
+	next []byte // the next key, nil if not read yet or at EOF
+	prev []byte // the previously consumed key
+	eof  bool
+}
+
+// peek returns the next key without consuming it, or nil at EOF.
+func (kr *keyReader) peek() ([]byte, error) {
+	if kr.next != nil || kr.eof {
+		return kr.next, nil
+	}
+	key := make([]byte, kr.size)
+	_, err := io.ReadFull(kr.r, key)
+	switch err {
+	case nil:
+	case io.EOF:
+		kr.eof = true
+		return nil, nil
+	default:
+		return nil, err
+	}
+	if kr.prev != nil && bytes.Compare(kr.prev, key) >= 0 {
+		return nil, errors.New("keys not in strictly ascending order")
+	}
+	kr.next = key
+	return key, nil
+}
+
+// pop consumes the next key.
+func (kr *keyReader) pop() error {
+	if _, err := kr.peek(); err != nil {
+		return err
+	}
+	kr.prev, kr.next = kr.next, nil
+	return nil
+}
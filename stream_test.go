@@ -0,0 +1,52 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRootHashFromReader(t *testing.T) {
+	data := getFreshData(128)
+	var buf bytes.Buffer
+	for _, k := range data.All() {
+		buf.Write(k)
+	}
+
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	root := s.Update(data, data, s.N, s.Base, Set)
+
+	cache := CacheBranch(make(map[string][]byte))
+	r, err := s.RootHashFromReader(bytes.NewReader(buf.Bytes()), cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root, r) {
+		t.Fatal("roots mismatch")
+	}
+	if cache.Entries() != s.CacheEntries() {
+		t.Fatal("cache filled differently than by Update")
+	}
+
+	// the filled cache is usable
+	filled := NewSMT([]byte{0x42}, cache, hash)
+	if !bytes.Equal(root, filled.RootHash(nil, s.N, s.Base)) {
+		t.Fatal("root not cached")
+	}
+
+	// empty input
+	r, err = s.RootHashFromReader(bytes.NewReader(nil), nil)
+	if err != nil || !bytes.Equal(r, s.defaultHash(s.N)) {
+		t.Fatal("wrong root for no keys")
+	}
+
+	// unsorted, duplicate and truncated input
+	for _, in := range [][]byte{
+		append(append([]byte{}, data.At(1)...), data.At(0)...),
+		append(append([]byte{}, data.At(0)...), data.At(0)...),
+		buf.Bytes()[:buf.Len()-1],
+	} {
+		if _, err = s.RootHashFromReader(bytes.NewReader(in), nil); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package gosmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// sumSize is the size, in bytes, of the sum annotation of a node.
+const sumSize = 8
+
+// Balances looks up the balance of the leaf of a key in a SumSMT.
+type Balances interface {
+	// Balance returns the balance of the leaf with the provided key.
+	Balance(key []byte) int64
+}
+
+// BalanceMap is a Balances backed by a map from key to balance.
+type BalanceMap map[string]int64
+
+// Balance returns the balance of key, zero if unknown.
+func (b BalanceMap) Balance(key []byte) int64 {
+	return b[string(key)]
+}
+
+// SumSMT is a Merkle sum sparse Merkle tree, e.g., for proofs of liabilities.
+// Every node is the hash of the node concatenated with the sum of the balances
+// of all leaves below it. Interior nodes are computed with the interiorHash of
+// the SMT over the annotated children, so each hash commits to the sums of its
+// children. Default nodes carry sum zero. Nodes are nil if a balance is
+// negative or the sum of the balances overflows.
+type SumSMT struct {
+	smt          *SMT
+	balances     Balances
+	Base         []byte // key of left-most leaf of a subtree, fixed in size.
+	N            uint64 // output length, in bits, of hash
+	defaultNodes [][]byte
+}
+
+// NewSumSMT creates a new SumSMT. Like for NewSMT, it requires a default empty
+// leaf constant c, a caching strategy cache and a hash function. The balance
+// of each set key is looked up in balances.
+func NewSumSMT(c []byte, cache Cache, balances Balances,
+	hash func(data ...[]byte) []byte) *SumSMT {
+	s := new(SumSMT)
+	s.smt = NewSMT(c, cache, hash)
+	s.balances = balances
+	s.Base = s.smt.Base
+	s.N = s.smt.N
+
+	s.defaultNodes = make([][]byte, s.N+1)
+	s.defaultNodes[0] = annotate(s.smt.leafHash(Empty, nil), 0)
+	for i := 1; i <= int(s.N); i++ {
+		s.defaultNodes[i] = s.interiorHash(s.defaultNodes[i-1],
+			s.defaultNodes[i-1], uint64(i), nil)
+	}
+	return s
+}
+
+// nodes returns the nodes of the SumSMT, annotated with their sums.
+func (s *SumSMT) nodes() nodes {
+	return nodes{
+		leaf: func(base, value []byte) []byte {
+			if bytes.Equal(value, Empty) {
+				return s.defaultNodes[0]
+			}
+			return s.leafHash(s.balances.Balance(base), base)
+		},
+		interior: s.interiorHash,
+		defaults: s.defaultNodes,
+	}
+}
+
+// Update updates keys to the value, where Set uses the balance of each key
+// and Empty removes the key. Returns
+// nil if a balance is negative or the sum of the balances overflows.
+func (s *SumSMT) Update(d LeafStore, keys Trie[Key], height uint64, base, value []byte) []byte {
+	return s.smt.update(s.nodes(), leafStore(d), keys, height, base, value)
+}
+
+// RootHash returns the root node, hash and sum, of a subtree with certain height.
+func (s *SumSMT) RootHash(d LeafStore, height uint64, base []byte) []byte {
+	return s.smt.rootHash(s.nodes(), leafStore(d), height, base)
+}
+
+// AuditPath generates an audit path of sibling nodes, hashes and sums.
+func (s *SumSMT) AuditPath(d LeafStore, height uint64, base, key []byte) [][]byte {
+	return s.smt.auditPath(s.nodes(), leafStore(d), height, base, key, 0)
+}
+
+// SubtreeAuditPath generates an audit path for the subtree with the provided
+// height and base, e.g., to prove the sum of all balances below a prefix.
+func (s *SumSMT) SubtreeAuditPath(d LeafStore, height uint64, base []byte) [][]byte {
+	return s.smt.auditPath(s.nodes(), leafStore(d), s.N, s.Base, base, height)
+}
+
+// VerifyAuditPath verifies an audit path for key with the provided balance.
+// Besides the hashes, it checks that all sums in the path are non-negative and
+// add up to the total of the root.
+func (s *SumSMT) VerifyAuditPath(ap [][]byte, key []byte, balance int64,
+	root []byte) bool {
+	return s.smt.verify(s.nodes(), ap, 0, key, s.leafHash(balance, key), root)
+}
+
+// VerifyNonMembership verifies an audit path showing that key is not set.
+func (s *SumSMT) VerifyNonMembership(ap [][]byte, key, root []byte) bool {
+	return s.smt.verify(s.nodes(), ap, 0, key, s.defaultNodes[0], root)
+}
+
+// VerifySubtreeAuditPath verifies an audit path for the subtree root node with
+// the provided height and base.
+func (s *SumSMT) VerifySubtreeAuditPath(ap [][]byte, height uint64,
+	base, node, root []byte) bool {
+	if height > s.N {
+		return false
+	}
+	if _, ok := nodeSum(node); !ok {
+		return false
+	}
+	return s.smt.verify(s.nodes(), ap, height, base, node, root)
+}
+
+// Sum returns the sum of a node, e.g., the total of all balances for the root.
+func (s *SumSMT) Sum(node []byte) int64 {
+	sum, _ := nodeSum(node)
+	return sum
+}
+
+// CacheEntries returns the number of cache entries.
+func (s *SumSMT) CacheEntries() int {
+	return s.smt.CacheEntries()
+}
+
+// CacheSize returns an estimate of the memory used by the cache in bytes.
+func (s *SumSMT) CacheSize() int {
+	return s.smt.CacheSize()
+}
+
+// leafHash returns the leaf node for a set key with the provided balance, or
+// nil if the balance is negative.
+func (s *SumSMT) leafHash(balance int64, base []byte) []byte {
+	if balance < 0 {
+		return nil
+	}
+	return annotate(s.smt.leafHash(encodeSum(balance), base), balance)
+}
+
+// interiorHash returns the non-leaf node of the SumSMT, or nil if the sums of
+// the children are negative or overflow.
+func (s *SumSMT) interiorHash(left, right []byte,
+	height uint64, base []byte) []byte {
+	l, lok := nodeSum(left)
+	r, rok := nodeSum(right)
+	if !lok || !rok || l > math.MaxInt64-r {
+		return nil
+	}
+	return annotate(s.smt.interiorHash(left, right, height, base), l+r)
+}
+
+// annotate returns the node for a hash and its sum.
+func annotate(hash []byte, sum int64) []byte {
+	return append(append(make([]byte, 0, len(hash)+sumSize), hash...),
+		encodeSum(sum)...)
+}
+
+func encodeSum(sum int64) []byte {
+	b := make([]byte, sumSize)
+	binary.BigEndian.PutUint64(b, uint64(sum))
+	return b
+}
+
+// nodeSum returns the sum of a node and if it is a valid non-negative sum.
+func nodeSum(node []byte) (int64, bool) {
+	if len(node) <= sumSize {
+		return 0, false
+	}
+	sum := int64(binary.BigEndian.Uint64(node[len(node)-sumSize:]))
+	return sum, sum >= 0
+}
@@ -0,0 +1,119 @@
+package gosmt
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestSumSMT(t *testing.T) {
+	balances := make(BalanceMap)
+	s := NewSumSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)),
+		balances, hash)
+
+	data := getFreshData(32)
+	var total int64
+	for i := 0; i < data.Len(); i++ {
+		balances[string(data.At(i))] = int64(i * 100)
+		total += int64(i * 100)
+	}
+	root := s.Update(data, data, s.N, s.Base, Set)
+	if !bytes.Equal(root, s.RootHash(data, s.N, s.Base)) {
+		t.Fatal("roots mismatch")
+	}
+	if s.Sum(root) != total {
+		t.Fatalf("expected total %d, got %d", total, s.Sum(root))
+	}
+
+	for i := 0; i < data.Len(); i++ {
+		ap := s.AuditPath(data, s.N, s.Base, data.At(i))
+		if !s.VerifyAuditPath(ap, data.At(i), int64(i*100), root) {
+			t.Fatal("failed to verify valid proof")
+		}
+		if s.VerifyAuditPath(ap, data.At(i), int64(i*100+1), root) {
+			t.Fatal("verified proof with wrong balance")
+		}
+	}
+
+	// a sibling lying about its sum, keeping the hash, must fail
+	ap := s.AuditPath(data, s.N, s.Base, data.At(0))
+	for i := range ap {
+		if s.Sum(ap[i]) == 0 {
+			continue
+		}
+		forged := annotate(ap[i][:len(ap[i])-sumSize], -1)
+		ap[i], forged = forged, ap[i]
+		if s.VerifyAuditPath(ap, data.At(0), 0, root) {
+			t.Fatal("verified proof with negative sum")
+		}
+		ap[i] = forged
+	}
+
+	key := hash([]byte("non-member"))
+	ap = s.AuditPath(data, s.N, s.Base, key)
+	if !s.VerifyNonMembership(ap, key, root) {
+		t.Fatal("failed to verify valid non-membership proof")
+	}
+	if s.VerifyAuditPath(ap, key, 0, root) {
+		t.Fatal("verified membership of non-member")
+	}
+
+	// the sum of the left half of the tree
+	split := bitSplit(s.Base, 0)
+	l, _ := data.Split(split)
+	node := s.RootHash(l, s.N-1, s.Base)
+	ap = s.SubtreeAuditPath(data, s.N-1, s.Base)
+	if !s.VerifySubtreeAuditPath(ap, s.N-1, s.Base, node, root) {
+		t.Fatal("failed to verify valid subtree proof")
+	}
+
+	// overflow
+	balances[string(data.At(0))] = math.MaxInt64
+	if s.Update(data, data.Slice(0, 1), s.N, s.Base, Set) != nil {
+		t.Fatal("expected overflow")
+	}
+
+	// negative balance
+	balances[string(data.At(0))] = -1
+	if s.Update(data, data.Slice(0, 1), s.N, s.Base, Set) != nil {
+		t.Fatal("expected nil root for negative balance")
+	}
+	if s.VerifyAuditPath(ap, data.At(0), -1, root) {
+		t.Fatal("verified proof with negative balance")
+	}
+}
+
+func TestCountSMT(t *testing.T) {
+	s := NewCountSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	data := getFreshData(64)
+	root := s.Update(data, data, s.N, s.Base, Set)
+	if !s.VerifyCount(nil, s.N, s.Base, root, int64(data.Len()), root) {
+		t.Fatal("wrong number of keys in tree")
+	}
+
+	// count the keys below every prefix of two bits
+	height := s.N - 2
+	var total int64
+	for i := byte(0); i < 4; i++ {
+		base := make([]byte, s.N/8)
+		base[0] = i << 6
+		l, _ := data.Split(append([]byte{(i + 1) << 6}, base[1:]...))
+		_, sub := l.Split(base)
+		if i == 3 { // (i+1)<<6 overflows
+			_, sub = data.Split(base)
+		}
+
+		node := s.RootHash(sub, height, base)
+		ap := s.SubtreeAuditPath(data, height, base)
+		if !s.VerifyCount(ap, height, base, node, int64(sub.Len()), root) {
+			t.Fatalf("failed to verify count for prefix %d", i)
+		}
+		if s.VerifyCount(ap, height, base, node, int64(sub.Len()+1), root) {
+			t.Fatalf("verified wrong count for prefix %d", i)
+		}
+		total += int64(sub.Len())
+	}
+	if total != int64(data.Len()) {
+		t.Fatal("prefix counts do not add up")
+	}
+}
@@ -0,0 +1,173 @@
+package gosmt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// operations of the sync protocol
+const (
+	syncRoot     byte = iota + 1 // get the signed root
+	syncChildren                 // get the roots of the children of a node
+	syncLeaves                   // get all keys below a node
+	syncDone                     // end the session
+)
+
+// syncRequest is a request for a node at (Height, Base) in the sync protocol.
+type syncRequest struct {
+	Op     byte
+	Height uint64
+	Base   []byte
+}
+
+// syncResponse is the response to a syncRequest.
+type syncResponse struct {
+	Root        *SignedRoot
+	Left, Right []byte
+	Keys        [][]byte
+	Err         string
+}
+
+// ServeSync serves the sync protocol on rw for a replica with the keys d and
+// the signed root of them, until the peer ends the session or rw is closed.
+func (s *SMT) ServeSync(rw io.ReadWriter, d LeafStore, root *SignedRoot) error {
+	d, n := leafStore(d), s.nodes()
+	dec, enc := gob.NewDecoder(rw), gob.NewEncoder(rw)
+	for {
+		var req syncRequest
+		if err := dec.Decode(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if req.Op == syncDone {
+			return nil
+		}
+
+		var resp syncResponse
+		switch {
+		case req.Op == syncRoot:
+			resp.Root = root
+		case req.Height > s.N || uint64(len(req.Base)) != s.N/8:
+			resp.Err = "invalid node"
+		case req.Op == syncChildren && req.Height > 0:
+			split := bitSplit(req.Base, s.N-req.Height)
+			l, r := s.subtree(d, req.Height, req.Base).SplitAt(split)
+			resp.Left = s.rootHash(n, l, req.Height-1, req.Base)
+			resp.Right = s.rootHash(n, r, req.Height-1, split)
+		case req.Op == syncLeaves:
+			s.subtree(d, req.Height, req.Base).Iterate(func(key []byte) bool {
+				resp.Keys = append(resp.Keys, key)
+				return true
+			})
+		default:
+			resp.Err = "invalid request"
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Sync synchronizes a replica with the keys d with a peer serving the sync
+// protocol on rw. The root of the peer is verified with the public key pub,
+// and every subtree root and set of keys received is checked against it.
+// Starting from the root, Sync only descends where the local nodes differ
+// from those of the peer, and fetches all keys of subtrees that are empty
+// locally. Returns the difference from d to the keys of the peer and the
+// signed root of the peer. Values are not exchanged, keys with different
+// values are returned as changed.
+func (s *SMT) Sync(rw io.ReadWriter, d LeafStore,
+	pub ed25519.PublicKey) (*Difference, *SignedRoot, error) {
+	c := &syncClient{s: s, n: s.nodes(), dec: gob.NewDecoder(rw), enc: gob.NewEncoder(rw)}
+	resp, err := c.request(syncRoot, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Root == nil || !resp.Root.Verify(pub) {
+		return nil, nil, errors.New("invalid signed root")
+	}
+
+	diff := new(differences)
+	if err = c.sync(leafStore(d), s.N, s.Base, resp.Root.Root, diff); err != nil {
+		return nil, nil, err
+	}
+	if err = c.enc.Encode(&syncRequest{Op: syncDone}); err != nil {
+		return nil, nil, err
+	}
+	return diff.difference(), resp.Root, nil
+}
+
+type syncClient struct {
+	s   *SMT
+	n   nodes // the nodes of s, built once for the whole session
+	dec *gob.Decoder
+	enc *gob.Encoder
+}
+
+func (c *syncClient) request(op byte, height uint64,
+	base []byte) (*syncResponse, error) {
+	if err := c.enc.Encode(&syncRequest{Op: op, Height: height, Base: base}); err != nil {
+		return nil, err
+	}
+	resp := new(syncResponse)
+	if err := c.dec.Decode(resp); err != nil {
+		return nil, err
+	}
+	if resp.Err != "" {
+		return nil, errors.New(resp.Err)
+	}
+	return resp, nil
+}
+
+// sync synchronizes the subtree d with height and base, where the peer has
+// the verified node expected.
+func (c *syncClient) sync(d LeafStore, height uint64, base,
+	expected []byte, diff *differences) error {
+	s := c.s
+	local := s.rootHash(c.n, d, height, base)
+	switch {
+	case bytes.Equal(local, expected):
+		return nil
+	case bytes.Equal(expected, s.defaultHash(height)):
+		d.Iterate(func(key []byte) bool {
+			diff.removed = append(diff.removed, key)
+			return true
+		})
+		return nil
+	case bytes.Equal(local, s.defaultHash(height)):
+		resp, err := c.request(syncLeaves, height, base)
+		if err != nil {
+			return err
+		}
+		keys := NewKeys(resp.Keys...)
+		if keys.Len() != len(resp.Keys) ||
+			keys.Count(base, s.subtreeEnd(height, base)) != keys.Len() ||
+			!bytes.Equal(s.verifier().RootHash(keys, height, base), expected) {
+			return errors.New("keys do not match the signed root")
+		}
+		diff.added = append(diff.added, keys.All()...)
+		return nil
+	case height == 0:
+		diff.changed = append(diff.changed, base)
+		return nil
+	}
+
+	resp, err := c.request(syncChildren, height, base)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(s.interiorHash(resp.Left, resp.Right, height, base), expected) {
+		return errors.New("children do not match the signed root")
+	}
+	split := bitSplit(base, s.N-height)
+	l, r := d.SplitAt(split)
+	if err = c.sync(l, height-1, base, resp.Left, diff); err != nil {
+		return err
+	}
+	return c.sync(r, height-1, split, resp.Right, diff)
+}
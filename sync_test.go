@@ -0,0 +1,65 @@
+package gosmt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the peer is ahead of the lagging replica, which also has a stale key
+	data := getFreshData(256)
+	lagging := data.Slice(0, 200).Insert(hash([]byte("stale")))
+	peerData := data.Insert(getFreshData(16).All()...)
+
+	peer := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	signed := SignRoot(priv, 1, peer.BulkLoad(peerData))
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	s.BulkLoad(lagging)
+
+	sync := func(pub ed25519.PublicKey) (*Difference, *SignedRoot, error) {
+		client, server := net.Pipe()
+		defer client.Close()
+		done := make(chan error, 1) // not read if Sync fails
+		go func() {
+			done <- peer.ServeSync(server, peerData, signed)
+			server.Close()
+		}()
+		diff, root, err := s.Sync(client, lagging, pub)
+		if err == nil {
+			err = <-done
+		}
+		return diff, root, err
+	}
+
+	diff, root, err := sync(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Added.Len() != 72 || diff.Removed.Len() != 1 || diff.Changed.Len() != 0 {
+		t.Fatalf("wrong difference: %d added, %d removed",
+			diff.Added.Len(), diff.Removed.Len())
+	}
+	lagging = lagging.Insert(diff.Added.All()...).Remove(diff.Removed.All()...)
+	s.Update(lagging, diff.Added, s.N, s.Base, Set)
+	if !bytes.Equal(s.Update(lagging, diff.Removed, s.N, s.Base, Empty), root.Root) {
+		t.Fatal("roots mismatch after sync")
+	}
+
+	// nothing left to sync
+	if diff, _, err = sync(pub); err != nil || diff.Added.Len()+diff.Removed.Len() != 0 {
+		t.Fatal("expected no difference")
+	}
+
+	// a root signed by someone else
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, _, err = sync(other); err == nil {
+		t.Fatal("synced with invalid signed root")
+	}
+}
@@ -0,0 +1,135 @@
+package gosmt
+
+import "errors"
+
+var (
+	// ErrTxDone is returned when committing a transaction that is already
+	// committed or rolled back.
+	ErrTxDone = errors.New("transaction already committed or rolled back")
+	// ErrTxConflict is returned when committing a transaction after the
+	// cache of the SMT changed since Begin, by an update or another commit.
+	// The transaction is rolled back and has to be redone.
+	ErrTxConflict = errors.New("transaction conflicts with a change since begin")
+)
+
+// Tx is a transaction of updates to a SMT. It is a SMT whose cache is an
+// overlay: all cache writes of its updates are staged in the overlay and only
+// merged into the cache of the SMT on Commit, so readers of the SMT keep
+// seeing the committed tree until then, and a Rollback leaves no trace.
+// Transactions are optimistic: of overlapping transactions, only the first
+// to commit succeeds.
+type Tx struct {
+	*SMT
+	overlay *overlay
+	base    *uint64 // generation of the cache of the SMT
+	gen     uint64  // generation of the cache of the SMT at Begin
+	done    bool
+}
+
+// Begin starts a new transaction. Only the caching strategies of this
+// package can stage writes, for other caches Begin returns an error.
+func (s *SMT) Begin() (*Tx, error) {
+	base, ok := s.cache.(stagingCache)
+	if !ok {
+		return nil, errors.New("cache does not support transactions")
+	}
+	tx := new(Tx)
+	tx.overlay = &overlay{base: base, staged: make(map[string][]byte)}
+	tx.base, tx.gen = s.gen, *s.gen
+	tx.SMT = new(SMT)
+	*tx.SMT = *s
+	tx.SMT.cache = tx.overlay
+	tx.SMT.gen = new(uint64)
+	return tx, nil
+}
+
+// Commit merges all staged cache writes into the cache of the SMT, or returns
+// ErrTxConflict if the cache changed since Begin. It must not run
+// concurrently with other use of the SMT.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if *tx.base != tx.gen {
+		tx.Rollback()
+		return ErrTxConflict
+	}
+	tx.done = true
+	*tx.base++
+	for key, value := range tx.overlay.staged {
+		tx.overlay.base.set(key, value)
+	}
+	return nil
+}
+
+// Rollback discards all staged cache writes.
+func (tx *Tx) Rollback() {
+	tx.done = true
+	tx.overlay.staged = make(map[string][]byte)
+}
+
+// overlay is a Cache that stages the writes of a caching strategy on top of
+// a base cache of the same strategy.
+type overlay struct {
+	base   stagingCache
+	staged map[string][]byte // nil values are staged deletions
+}
+
+// Exists checks if a value exists in the cache.
+func (o *overlay) Exists(height uint64, base []byte) bool {
+	return o.has(cacheKey(height, base))
+}
+
+// Get returns a value that exists from the cache.
+func (o *overlay) Get(height uint64, base []byte) []byte {
+	key := cacheKey(height, base)
+	if value, staged := o.staged[key]; staged {
+		return value
+	}
+	return o.base.Get(height, base)
+}
+
+// HashCache hashes the provided values and maybe caches.
+func (o *overlay) HashCache(left, right []byte, height uint64, base, split []byte,
+	interiorHash func(left, right []byte, height uint64, base []byte) []byte,
+	defaultHashes [][]byte) []byte {
+	h := interiorHash(left, right, height, base)
+	o.base.cache(h, left, right, height, base, split, defaultHashes, o.set)
+	return h
+}
+
+// Entries returns the number of entries in the cache.
+func (o *overlay) Entries() int {
+	entries := o.base.Entries()
+	for key, value := range o.staged {
+		switch exists := o.base.has(key); {
+		case value == nil && exists:
+			entries--
+		case value != nil && !exists:
+			entries++
+		}
+	}
+	return entries
+}
+
+// Size returns an estimate of the memory used by the cache in bytes,
+// including the staged writes.
+func (o *overlay) Size() int {
+	return o.base.Size() + mapSize(o.staged)
+}
+
+func (o *overlay) cache(h, left, right []byte, height uint64, base, split []byte,
+	defaultHashes [][]byte, set func(key string, value []byte)) {
+	o.base.cache(h, left, right, height, base, split, defaultHashes, set)
+}
+
+func (o *overlay) set(key string, value []byte) {
+	o.staged[key] = value
+}
+
+func (o *overlay) has(key string) bool {
+	if value, staged := o.staged[key]; staged {
+		return value != nil
+	}
+	return o.base.has(key)
+}
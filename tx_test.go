@@ -0,0 +1,109 @@
+package gosmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTx(t *testing.T) {
+	for _, cache := range []Cache{CacheBranch(make(map[string][]byte)),
+		CacheBranchPlus(make(map[string][]byte)), NewCacheBranchMinus(0.7)} {
+		s := NewSMT([]byte{0x42}, cache, hash)
+		data := getFreshData(64)
+		root := s.BulkLoad(data)
+		entries := s.CacheEntries()
+
+		// roll back an update
+		tx, err := s.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := getFreshData(8)
+		newdata := data.Insert(keys.All()...)
+		newroot := tx.Update(newdata, keys, tx.N, tx.Base, Set)
+		if !bytes.Equal(newroot, tx.RootHash(newdata, s.N, s.Base)) {
+			t.Fatal("roots mismatch within transaction")
+		}
+		// readers see the committed root, unaffected by the staged writes
+		if s.CacheEntries() != entries ||
+			!bytes.Equal(root, s.RootHash(data, s.N, s.Base)) {
+			t.Fatal("transaction changed the committed tree")
+		}
+		tx.Rollback()
+		if tx.Commit() != ErrTxDone {
+			t.Fatal("committed a rolled back transaction")
+		}
+		if s.CacheEntries() != entries ||
+			!bytes.Equal(root, s.RootHash(data, s.N, s.Base)) {
+			t.Fatal("rollback changed the committed tree")
+		}
+
+		// commit an update, removing keys
+		if tx, err = s.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		removed := data.Slice(10, 20)
+		newdata = data.Remove(removed.All()...)
+		newroot = tx.Update(newdata, removed, tx.N, tx.Base, Empty)
+		staged := tx.CacheEntries()
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if s.CacheEntries() != staged {
+			t.Fatal("wrong number of cache entries after commit")
+		}
+		if !bytes.Equal(newroot, s.RootHash(newdata, s.N, s.Base)) {
+			t.Fatal("roots mismatch after commit")
+		}
+		if !bytes.Equal(newroot, NewSMT([]byte{0x42}, CacheNothing(0), hash).
+			RootHash(newdata, s.N, s.Base)) {
+			t.Fatal("committed cache inconsistent")
+		}
+	}
+
+	if _, err := NewSMT([]byte{0x42}, CacheNothing(0), hash).Begin(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxConflict(t *testing.T) {
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	data := getFreshData(64)
+	s.BulkLoad(data)
+
+	// two overlapping transactions: only the first to commit succeeds
+	tx1, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys1, keys2 := getFreshData(4), getFreshData(4)
+	data1 := data.Insert(keys1.All()...)
+	root1 := tx1.Update(data1, keys1, tx1.N, tx1.Base, Set)
+	tx2.Update(data.Insert(keys2.All()...), keys2, tx2.N, tx2.Base, Set)
+	if err = tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if tx2.Commit() != ErrTxConflict {
+		t.Fatal("committed a transaction overlapping a committed one")
+	}
+	if !bytes.Equal(root1, s.RootHash(data1, s.N, s.Base)) ||
+		!bytes.Equal(root1, NewSMT([]byte{0x42}, CacheNothing(0), hash).
+			RootHash(data1, s.N, s.Base)) {
+		t.Fatal("conflicting transaction changed the committed tree")
+	}
+
+	// an update of the SMT itself also conflicts
+	tx, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Update(data1.Insert(keys2.All()...), keys2, tx.N, tx.Base, Set)
+	s.Update(data1.Remove(keys1.All()...), keys1, s.N, s.Base, Empty)
+	if tx.Commit() != ErrTxConflict {
+		t.Fatal("committed a transaction overlapping an update")
+	}
+}
@@ -1,6 +1,9 @@
 package gosmt
 
-import "crypto/sha512"
+import (
+	"bytes"
+	"crypto/sha512"
+)
 
 // thank you https://play.golang.org/p/sycUxCZyxf.
 
@@ -32,3 +35,41 @@ func hash(data ...[]byte) []byte {
 	}
 	return hasher.Sum(nil)
 }
+
+// bitFill returns a new bit string whose bits at positions [i, N)
+// (big-endian) are all set to 1. Given the base of a node in SMT at
+// position N-height, this is the key of its right-most leaf.
+func bitFill(bits []byte, i uint64) (fill []byte) {
+	fill = make([]byte, len(bits))
+	copy(fill, bits)
+	for j := i; j < uint64(len(bits))*8; j++ {
+		bitSet(fill, j)
+	}
+	return
+}
+
+// bitIncrement returns a new bit string that is bits plus one, or nil if
+// bits is all ones.
+func bitIncrement(bits []byte) []byte {
+	inc := make([]byte, len(bits))
+	copy(inc, bits)
+	for i := len(inc) - 1; i >= 0; i-- {
+		inc[i]++
+		if inc[i] != 0 {
+			return inc
+		}
+	}
+	return nil
+}
+
+// bitPrefixEqual checks whether the first n bits of a and b are equal.
+func bitPrefixEqual(a, b []byte, n uint64) bool {
+	if !bytes.Equal(a[:n/8], b[:n/8]) {
+		return false
+	}
+	if n%8 == 0 {
+		return true
+	}
+	mask := byte(0xff << (8 - n%8))
+	return a[n/8]&mask == b[n/8]&mask
+}
@@ -0,0 +1,267 @@
+package gosmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update", false, "regenerate testdata/vectors.json")
+
+// vectorsFile holds the test vectors. All byte strings are hex encoded.
+var vectorsFile = filepath.Join("testdata", "vectors.json")
+
+// vectors are test vectors of roots and audit paths for other
+// implementations of the SMT.
+type vectors struct {
+	Description string       `json:"description"`
+	Hash        string       `json:"hash"`
+	Constant    string       `json:"constant"`
+	Set         string       `json:"set"`
+	Empty       string       `json:"empty"`
+	Cases       []vectorCase `json:"cases"`
+}
+
+// vectorCase is a tree of keys, where the keys in Values commit to a value
+// and all others are Set, and proofs against its root.
+type vectorCase struct {
+	Description string            `json:"description"`
+	Keys        []string          `json:"keys"`
+	Values      map[string]string `json:"values,omitempty"`
+	Root        string            `json:"root"`
+	Proofs      []vectorProof     `json:"proofs"`
+}
+
+// vectorProof is an audit path for the leaf of Key with Value, and whether
+// VerifyAuditPath accepts it against the root of its case.
+type vectorProof struct {
+	Description string   `json:"description"`
+	Key         string   `json:"key"`
+	Value       string   `json:"value"`
+	AuditPath   []string `json:"auditPath"`
+	Valid       bool     `json:"valid"`
+}
+
+func TestVectors(t *testing.T) {
+	v := generateVectors()
+	for _, c := range v.Cases {
+		checkVectorCase(t, c)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = append(out, '\n')
+	if *updateVectors {
+		if err = os.WriteFile(vectorsFile, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	stored, err := os.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, out) {
+		t.Fatalf("%s differs from the generated vectors, if the change is "+
+			"intended run go test -run TestVectors -update", vectorsFile)
+	}
+	var read vectors
+	if err = json.Unmarshal(stored, &read); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range read.Cases {
+		checkVectorCase(t, c)
+	}
+}
+
+// checkVectorCase checks the root and proofs of c with a SMT built from its
+// keys and values.
+func checkVectorCase(t *testing.T, c vectorCase) {
+	s := NewSMT([]byte{0x42}, CacheNothing(0), hash)
+	values := make(mapValues)
+	for k, v := range c.Values {
+		values[string(unhex(k))] = unhex(v)
+	}
+	s.Values = values
+	var keys [][]byte
+	for _, k := range c.Keys {
+		keys = append(keys, unhex(k))
+	}
+	root := s.RootHash(NewKeys(keys...), s.N, s.Base)
+	if hex.EncodeToString(root) != c.Root {
+		t.Fatalf("%s: unexpected root", c.Description)
+	}
+	for _, p := range c.Proofs {
+		var ap [][]byte
+		for _, h := range p.AuditPath {
+			ap = append(ap, unhex(h))
+		}
+		if s.VerifyAuditPath(ap, unhex(p.Key), unhex(p.Value), root) != p.Valid {
+			t.Fatalf("%s: %s: expected valid %v", c.Description, p.Description, p.Valid)
+		}
+	}
+}
+
+// generateVectors builds the test vectors from scratch.
+func generateVectors() *vectors {
+	v := &vectors{
+		Description: "Test vectors for gosmt. Each case is a tree of keys, where " +
+			"the keys in values commit to that value and all other keys are set. " +
+			"Each proof is an audit path, ordered from the leaf to the root, for " +
+			"the leaf of key with value, and valid tells whether it verifies " +
+			"against the root of the case. Byte strings are hex encoded.",
+		Hash:     "SHA-512/256",
+		Constant: hex.EncodeToString([]byte{0x42}),
+		Set:      hex.EncodeToString(Set),
+		Empty:    hex.EncodeToString(Empty),
+	}
+
+	zero, ones := make([]byte, 32), bytes.Repeat([]byte{0xff}, 32)
+	lastBit := append([]byte{}, zero...)
+	lastBit[31] = 1
+	secondLastBit := append([]byte{}, zero...)
+	secondLastBit[31] = 2
+	numbered := func(from, n int) [][]byte {
+		keys := make([][]byte, n)
+		for i := range keys {
+			keys[i] = hash(binary.BigEndian.AppendUint64(nil, uint64(from+i)))
+		}
+		return NewKeys(keys...).All()
+	}
+	keys16 := numbered(0, 16)
+	keys4 := numbered(100, 4)
+
+	v.Cases = []vectorCase{
+		newVectorCase("empty tree", nil, nil, []vectorSpec{
+			{"non-member", zero, Empty, valid},
+			{"non-member claimed set", zero, Set, valid.not()},
+		}),
+		newVectorCase("single key", [][]byte{zero}, nil, []vectorSpec{
+			{"member", zero, Set, valid},
+			{"member claimed empty", zero, Empty, valid.not()},
+			{"non-member", ones, Empty, valid},
+		}),
+		newVectorCase("two keys that differ in the last bit",
+			[][]byte{zero, lastBit}, nil, []vectorSpec{
+				{"member with the left-most key", zero, Set, valid},
+				{"member with the other key", lastBit, Set, valid},
+				{"non-member sharing a 254-bit prefix with both keys", secondLastBit, Empty, valid},
+				{"proof of one key for the other key", zero, Set,
+					proofOf(lastBit).not()},
+			}),
+		newVectorCase("16 keys", keys16, nil, []vectorSpec{
+			{"member", keys16[0], Set, valid},
+			{"member in the middle", keys16[8], Set, valid},
+			{"non-member", hash([]byte("non-member")), Empty, valid},
+			{"member claimed empty", keys16[3], Empty, valid.not()},
+			{"member with a tampered sibling", keys16[5], Set, tampered},
+			{"member with a truncated path", keys16[5], Set, truncated},
+		}),
+		newVectorCase("keys with values", keys4, map[string][]byte{
+			string(keys4[0]): []byte("value 0"),
+			string(keys4[2]): []byte("value 2"),
+		}, []vectorSpec{
+			{"member with a value", keys4[0], []byte("value 0"), valid},
+			{"member without a value", keys4[1], Set, valid},
+			{"member with a value claimed set", keys4[2], Set, valid.not()},
+			{"member with another value", keys4[2], []byte("value 0"), valid.not()},
+			{"non-member", hash([]byte("non-member")), Empty, valid},
+		}),
+	}
+	return v
+}
+
+// vectorSpec specifies a proof for a case: the key and value to verify, and
+// how the audit path is made.
+type vectorSpec struct {
+	description string
+	key, value  []byte
+	path        pathSpec
+}
+
+// pathSpec makes an audit path for key, and tells if it should verify with
+// an honest value.
+type pathSpec struct {
+	make  func(s *SMT, d D, key []byte) [][]byte
+	valid bool
+}
+
+// not returns the path, expected not to verify.
+func (p pathSpec) not() pathSpec {
+	p.valid = false
+	return p
+}
+
+var (
+	valid = pathSpec{func(s *SMT, d D, key []byte) [][]byte {
+		return s.AuditPath(d, s.N, s.Base, key)
+	}, true}
+	tampered = pathSpec{func(s *SMT, d D, key []byte) [][]byte {
+		ap := valid.make(s, d, key)
+		ap[len(ap)-1] = append([]byte{}, ap[len(ap)-1]...)
+		ap[len(ap)-1][0] ^= 1
+		return ap
+	}, false}
+	truncated = pathSpec{func(s *SMT, d D, key []byte) [][]byte {
+		ap := valid.make(s, d, key)
+		return ap[:len(ap)-1]
+	}, false}
+)
+
+// proofOf is the audit path of another key.
+func proofOf(other []byte) pathSpec {
+	return pathSpec{func(s *SMT, d D, key []byte) [][]byte {
+		return s.AuditPath(d, s.N, s.Base, other)
+	}, true}
+}
+
+func newVectorCase(description string, keys [][]byte, values map[string][]byte,
+	specs []vectorSpec) vectorCase {
+	s := NewSMT([]byte{0x42}, CacheNothing(0), hash)
+	if values != nil {
+		s.Values = mapValues(values)
+	}
+	d := NewKeys(keys...)
+	c := vectorCase{
+		Description: description,
+		Keys:        []string{},
+		Root:        hex.EncodeToString(s.RootHash(d, s.N, s.Base)),
+	}
+	for _, k := range d.All() {
+		c.Keys = append(c.Keys, hex.EncodeToString(k))
+	}
+	if len(values) > 0 {
+		c.Values = make(map[string]string)
+		for k, v := range values {
+			c.Values[hex.EncodeToString([]byte(k))] = hex.EncodeToString(v)
+		}
+	}
+	for _, spec := range specs {
+		p := vectorProof{
+			Description: spec.description,
+			Key:         hex.EncodeToString(spec.key),
+			Value:       hex.EncodeToString(spec.value),
+			Valid:       spec.path.valid,
+		}
+		for _, h := range spec.path.make(s, d, spec.key) {
+			p.AuditPath = append(p.AuditPath, hex.EncodeToString(h))
+		}
+		c.Proofs = append(c.Proofs, p)
+	}
+	return c
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
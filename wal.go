@@ -0,0 +1,312 @@
+package gosmt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// record types of the WAL
+const (
+	walBatch  byte = 1
+	walCommit byte = 2
+)
+
+// walMaxRecord is the maximum size of a record payload, larger sizes are
+// considered a corrupt record.
+const walMaxRecord = 1 << 30
+
+// errChecksum is returned by readRecord if the payload checksum mismatches.
+var errChecksum = errors.New("checksum mismatch")
+
+// walHeader is the size of a record header: type, payload length and CRC-32.
+const walHeader = 9
+
+var (
+	// ErrRootMismatch is returned by Recover if a replayed batch does not
+	// result in the root logged for it.
+	ErrRootMismatch = errors.New("replayed batch resulted in another root")
+	// ErrUncommitted is returned by Append if the previous batch is not
+	// committed yet.
+	ErrUncommitted = errors.New("previous batch not committed")
+	// ErrNotRecovered is returned by Append if the log is not recovered yet.
+	ErrNotRecovered = errors.New("log not recovered")
+)
+
+// Batch is a batch of keys updated to the same value, as logged by a WAL.
+type Batch struct {
+	Keys  Key
+	Value []byte
+	// Values are per-key values, e.g., the marshaled Commitment of each key
+	// of a SMT with Values, to restore before replaying the batch. Nil if
+	// the leaves only depend on Value.
+	Values [][]byte
+	Root   []byte // the root after the update, nil if not committed
+}
+
+// WAL is a write-ahead log of update batches. Before updating a SMT, the
+// batch is appended and synced to the log, and after the update the new root
+// is committed and synced. On restart, Recover replays all committed batches
+// and checks their roots, while discarding an uncommitted batch at the end
+// of the log as well as a partially written final record. Corruption
+// anywhere else is an error, so no committed batch is ever discarded.
+type WAL struct {
+	f         *os.File
+	recovered bool  // Recover succeeded, so appending continues after it
+	pending   bool  // a batch is appended but not committed
+	size      int64 // the size of the log, without partially written records
+	batch     int64 // the size of the log before the pending batch
+}
+
+// OpenWAL opens, or creates, the log at path. Before appending to it, call
+// Recover to replay the log and discard incomplete batches, Append fails
+// until then.
+func OpenWAL(path string) (*WAL, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL{f: f}, nil
+}
+
+// Append logs the keys and value of a batch that is about to be applied.
+// If the leaves of the SMT depend on more than value, e.g., with Commitments
+// as Values, values has the state of each key needed to restore them, else
+// it is nil.
+func (w *WAL) Append(keys Key, value []byte, values [][]byte) error {
+	if !w.recovered {
+		return ErrNotRecovered
+	}
+	if w.pending {
+		return ErrUncommitted
+	}
+	if values != nil && len(values) != keys.Len() {
+		return errors.New("number of values and keys mismatch")
+	}
+	var buf bytes.Buffer
+	writeBytes(&buf, value)
+	writeUvarint(&buf, uint64(keys.Len()))
+	keys.Iterate(func(k []byte) bool {
+		writeBytes(&buf, k)
+		return true
+	})
+	writeUvarint(&buf, uint64(len(values)))
+	for _, v := range values {
+		writeBytes(&buf, v)
+	}
+	w.batch = w.size
+	if err := w.write(walBatch, buf.Bytes()); err != nil {
+		return err
+	}
+	w.pending = true
+	return nil
+}
+
+// Commit logs the root after applying the appended batch.
+func (w *WAL) Commit(root []byte) error {
+	if !w.pending {
+		return errors.New("no batch to commit")
+	}
+	if err := w.write(walCommit, root); err != nil {
+		return err
+	}
+	w.pending = false
+	return nil
+}
+
+// Abort discards the appended batch, e.g., if applying it failed, so that
+// appending can continue.
+func (w *WAL) Abort() error {
+	if !w.pending {
+		return errors.New("no batch to abort")
+	}
+	if err := w.truncate(w.batch); err != nil {
+		return err
+	}
+	w.pending = false
+	return nil
+}
+
+// Recover replays the log by calling apply for every committed batch, in
+// order, checking that the root apply returns matches the logged root. An
+// uncommitted batch or partially written record at the end of the log is
+// discarded, and the log is truncated so that appending can continue. A
+// corrupt record followed by more of the log, or a record with a corrupt
+// header, is an error, leaving the log as is.
+func (w *WAL) Recover(apply func(b *Batch) []byte) error {
+	info, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(w.f)
+	var valid int64 // offset after the last committed batch
+	var offset int64
+	var batch *Batch
+	for {
+		typ, payload, n, err := readRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break // end of log, or a partially written final record
+		}
+		if err != nil {
+			if err == errChecksum && offset+n == info.Size() {
+				break // a partially written final record
+			}
+			return fmt.Errorf("corrupt log at offset %d: %w", offset, err)
+		}
+		offset += n
+
+		switch {
+		case typ == walBatch && batch == nil:
+			if batch, err = decodeBatch(payload); err != nil {
+				return err
+			}
+		case typ == walCommit && batch != nil:
+			batch.Root = payload
+			if !bytes.Equal(apply(batch), batch.Root) {
+				return ErrRootMismatch
+			}
+			batch = nil
+			valid = offset
+		default:
+			return errors.New("corrupt log: unexpected record")
+		}
+	}
+
+	w.pending = false
+	if err := w.truncate(valid); err != nil {
+		return err
+	}
+	w.recovered = true
+	return nil
+}
+
+// Close closes the log.
+func (w *WAL) Close() error {
+	return w.f.Close()
+}
+
+// write appends and syncs a record: type, payload length, a CRC-32 of the
+// two, payload and a CRC-32 of all before it. The header has its own CRC so
+// that a corrupt length is never mistaken for a partially written record.
+// On failure, the partially written record is truncated if possible, so it
+// is not followed by later records.
+func (w *WAL) write(typ byte, payload []byte) error {
+	rec := make([]byte, walHeader, walHeader+len(payload)+4)
+	rec[0] = typ
+	binary.BigEndian.PutUint32(rec[1:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[5:], crc32.ChecksumIEEE(rec[:5]))
+	rec = append(rec, payload...)
+	rec = binary.BigEndian.AppendUint32(rec, crc32.ChecksumIEEE(rec))
+	_, err := w.f.Write(rec)
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if err != nil {
+		w.truncate(w.size)
+		return err
+	}
+	w.size += int64(len(rec))
+	return nil
+}
+
+// truncate truncates the log to size and continues writing from there.
+func (w *WAL) truncate(size int64) error {
+	if err := w.f.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	w.size = size
+	return w.f.Sync()
+}
+
+// readRecord reads a record, returning its type, payload and size in bytes.
+// Once a valid header is read, the size is the one it gives, even on error.
+func readRecord(r io.Reader) (byte, []byte, int64, error) {
+	head := make([]byte, walHeader)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, nil, 0, err
+	}
+	if crc32.ChecksumIEEE(head[:5]) != binary.BigEndian.Uint32(head[5:]) {
+		return 0, nil, 0, errors.New("header checksum mismatch")
+	}
+	size := binary.BigEndian.Uint32(head[1:5])
+	n := int64(len(head)) + int64(size) + 4
+	if size > walMaxRecord {
+		return 0, nil, n, errors.New("record too large")
+	}
+	rest := make([]byte, int64(size)+4)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, nil, n, err
+	}
+	payload, sum := rest[:len(rest)-4], rest[len(rest)-4:]
+	if crc32.Update(crc32.ChecksumIEEE(head), crc32.IEEETable, payload) !=
+		binary.BigEndian.Uint32(sum) {
+		return 0, nil, n, errChecksum
+	}
+	return head[0], payload, n, nil
+}
+
+func decodeBatch(payload []byte) (*Batch, error) {
+	r := bytes.NewReader(payload)
+	b := new(Batch)
+	var err error
+	if b.Value, err = readBytes(r); err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	for i := uint64(0); i < n; i++ {
+		k, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	b.Keys = NewKeys(keys...)
+	if n, err = binary.ReadUvarint(r); err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		v, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		b.Values = append(b.Values, v)
+	}
+	return b, nil
+}
+
+func writeUvarint(buf *bytes.Buffer, x uint64) {
+	buf.Write(binary.AppendUvarint(nil, x))
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
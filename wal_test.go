@@ -0,0 +1,265 @@
+package gosmt
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestWAL(t *testing.T) {
+	path := t.TempDir() + "/wal"
+	w, err := OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Recover(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	var d D
+	update := func(keys Key, value []byte) []byte {
+		if bytes.Equal(value, Empty) {
+			d = d.Remove(keys.All()...)
+		} else {
+			d = d.Insert(keys.All()...)
+		}
+		return s.Update(d, keys, s.N, s.Base, value)
+	}
+
+	var root []byte
+	var batches []Key
+	for i := 0; i < 4; i++ {
+		keys := getFreshData(8)
+		batches = append(batches, keys)
+		if err = w.Append(keys, Set, nil); err != nil {
+			t.Fatal(err)
+		}
+		root = update(keys, Set)
+		if err = w.Commit(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Append(batches[0].Slice(0, 4), Empty, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w.Append(batches[1], Empty, nil) != ErrUncommitted {
+		t.Fatal("appended batch without committing the previous")
+	}
+	// crash before committing, in the middle of writing another record
+	w.Close()
+	info, _ := os.Stat(path)
+	committed := info.Size()
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.Write([]byte{walCommit, 0, 0, 0, 32, 0x42})
+	f.Close()
+
+	// recover into a fresh tree
+	w, err = OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	d = D{}
+	var replayed int
+	err = w.Recover(func(b *Batch) []byte {
+		replayed++
+		return update(b.Keys, b.Value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 4 || !bytes.Equal(root, s.RootHash(d, s.N, s.Base)) {
+		t.Fatal("wrong state after recovery")
+	}
+	info, _ = os.Stat(path)
+	if info.Size() >= committed {
+		t.Fatal("uncommitted batch not discarded")
+	}
+
+	// logging continues after recovery, also after aborting a batch
+	if err = w.Append(batches[1], Empty, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Abort() == nil {
+		t.Fatal("aborted without a batch")
+	}
+	if err = w.Append(batches[0], Empty, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Commit(update(batches[0], Empty)); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	w, _ = OpenWAL(path)
+	replayed = 0
+	if err = w.Recover(func(b *Batch) []byte {
+		replayed++
+		return b.Root
+	}); err != nil || replayed != 5 {
+		t.Fatalf("wrong batches after abort: %d, %v", replayed, err)
+	}
+	w.Close()
+
+	// replaying with a tree that computes other roots fails
+	w, _ = OpenWAL(path)
+	defer w.Close()
+	other := NewSMT([]byte{0x43}, CacheNothing(0), hash)
+	err = w.Recover(func(b *Batch) []byte {
+		return other.RootHash(b.Keys, other.N, other.Base)
+	})
+	if err != ErrRootMismatch {
+		t.Fatalf("expected root mismatch, got %v", err)
+	}
+}
+
+func TestWALValues(t *testing.T) {
+	path := t.TempDir() + "/wal"
+	w, err := OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Recover(nil); err != nil {
+		t.Fatal(err)
+	}
+	com := NewCommitments(hash)
+	s := NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	s.Values = com
+
+	var d D
+	var root []byte
+	for i := 0; i < 4; i++ {
+		keys := getFreshData(8)
+		values := make([][]byte, keys.Len())
+		for j, k := range keys.All() {
+			c, err := com.Commit(k, []byte{byte(i), byte(j)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values[j], err = c.MarshalBinary(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Append(keys, Set, values); err != nil {
+			t.Fatal(err)
+		}
+		d = d.Insert(keys.All()...)
+		root = s.Update(d, keys, s.N, s.Base, Set)
+		if err = w.Commit(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	// recover the commitments along with the keys
+	w, err = OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	com = NewCommitments(hash)
+	s = NewSMT([]byte{0x42}, CacheBranch(make(map[string][]byte)), hash)
+	s.Values = com
+	d = D{}
+	err = w.Recover(func(b *Batch) []byte {
+		for i, k := range b.Keys.All() {
+			c := new(Commitment)
+			if err := c.UnmarshalBinary(b.Values[i]); err != nil {
+				t.Fatal(err)
+			}
+			com.Restore(k, c)
+		}
+		d = d.Insert(b.Keys.All()...)
+		return s.Update(d, b.Keys, s.N, s.Base, b.Value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := s.AuditPath(d, s.N, s.Base, d.At(0))
+	if !s.VerifyCommitment(ap, d.At(0), com.Get(d.At(0)), root) {
+		t.Fatal("failed to verify recovered commitment")
+	}
+	w.Close()
+
+	// corrupt the first batch: committed batches after it must not be dropped
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := f.Stat()
+	f.WriteAt([]byte{0xff}, 16)
+	f.Close()
+	w, err = OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err = w.Recover(func(b *Batch) []byte { return nil }); err == nil {
+		t.Fatal("recovered from a log corrupt before its end")
+	}
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Fatal("truncated a corrupt log")
+	}
+}
+
+func TestWALCorruptHeader(t *testing.T) {
+	path := t.TempDir() + "/wal"
+	w, err := OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Append(getFreshData(8), Set, nil) != ErrNotRecovered {
+		t.Fatal("appended before recovering")
+	}
+	if err = w.Recover(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err = w.Append(getFreshData(8), Set, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err = w.Commit(hash([]byte{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	info, _ := os.Stat(path)
+
+	// appending before recovering must not overwrite committed records
+	w, err = OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Append(getFreshData(8), Set, nil) != ErrNotRecovered {
+		t.Fatal("appended before recovering")
+	}
+	w.Close()
+
+	// a corrupt length in the first record is not a partially written one
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0x7f}, 1)
+	f.Close()
+	w, err = OpenWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var replayed int
+	if err = w.Recover(func(b *Batch) []byte {
+		replayed++
+		return b.Root
+	}); err == nil || replayed != 0 {
+		t.Fatal("recovered from a log with a corrupt header")
+	}
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Fatal("truncated a log with a corrupt header")
+	}
+	if w.Append(getFreshData(8), Set, nil) != ErrNotRecovered {
+		t.Fatal("appended after failing to recover")
+	}
+}